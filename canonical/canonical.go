@@ -0,0 +1,194 @@
+// Package canonical rewrites URLs into one canonical form, so the
+// crawler and the graph readers agree on when two URLs name the same
+// page. Without it "https://www.calpoly.edu#primary",
+// "http://WWW.calpoly.edu:80/" and "https://www.calpoly.edu" are three
+// nodes of the graph.
+package canonical
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Rules is a set of rewrites applied to every URL
+type Rules uint
+
+const (
+	// Fragment drops the "#..." part, which only points into the page
+	Fragment Rules = 1 << iota
+	// Case lower cases the scheme and the host, which are case
+	// insensitive. The path is left alone, since servers may tell
+	// /About from /about.
+	Case
+	// Port drops :80 from http and :443 from https URLs
+	Port
+	// Slash drops trailing slashes from the path, so /atoz/ is /atoz
+	// and https://www.calpoly.edu/ is https://www.calpoly.edu
+	Slash
+	// Query sorts the query parameters by name, keeping the order of
+	// repeated names, and drops empty ones
+	Query
+	// HTTPS rewrites http URLs without an explicit port to https
+	HTTPS
+	// WWW drops a leading "www." label from the host, unless the rest is
+	// a single label
+	WWW
+)
+
+// None keeps URLs as they are
+const None Rules = 0
+
+// Default is every rule but WWW, since www.example.com and example.com
+// need not be the same site
+const Default = Fragment | Case | Port | Slash | Query | HTTPS
+
+var ruleNames = []string{"fragment", "case", "port", "slash", "query", "https", "www"}
+
+// String returns the names of the rules in r separated by commas, or
+// "none"
+func (r Rules) String() string {
+	names := []string{}
+	for idx, name := range ruleNames {
+		if r&(1<<uint(idx)) != 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ",")
+}
+
+// ParseRules returns the rules named in a comma separated list of
+// fragment, case, port, slash, query, https and www. "default" stands
+// for Default, and "none" or an empty list for None, so "default,www"
+// adds WWW to the default rules.
+func ParseRules(list string) (Rules, error) {
+	r := None
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case "", "none":
+			continue
+		case "default":
+			r |= Default
+			continue
+		}
+		idx := 0
+		for idx < len(ruleNames) && ruleNames[idx] != name {
+			idx++
+		}
+		if idx == len(ruleNames) {
+			return None, fmt.Errorf("unknown canonicalization rule %q, want default, none or any of %s",
+				name, strings.Join(ruleNames, ", "))
+		}
+		r |= 1 << uint(idx)
+	}
+	return r, nil
+}
+
+// Set parses value with ParseRules, so Rules can be a command line flag
+func (r *Rules) Set(value string) error {
+	rules, err := ParseRules(value)
+	if err != nil {
+		return err
+	}
+	*r = rules
+	return nil
+}
+
+// URL returns the canonical form of raw. Anything but an absolute http
+// or https URL, such as the integer node IDs of SNAP files, is returned
+// as it is.
+func (r Rules) URL(raw string) string {
+	if r == None || len(raw) < 5 || !strings.EqualFold(raw[:4], "http") {
+		return raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil || !isWeb(u) {
+		return raw
+	}
+	return r.Apply(u).String()
+}
+
+// Apply returns a copy of u rewritten by r. URLs that are not absolute
+// http or https URLs are returned unchanged.
+func (r Rules) Apply(u *url.URL) *url.URL {
+	if r == None || !isWeb(u) {
+		return u
+	}
+	c := *u
+	if r&Case != 0 {
+		c.Scheme = strings.ToLower(c.Scheme)
+		c.Host = strings.ToLower(c.Host)
+	}
+	scheme := strings.ToLower(c.Scheme)
+	host, port := c.Hostname(), c.Port()
+	if r&Port != 0 && ((scheme == "http" && port == "80") || (scheme == "https" && port == "443")) {
+		port = ""
+	}
+	if r&WWW != 0 && len(host) > 4 && strings.EqualFold(host[:4], "www.") && strings.Contains(host[4:], ".") {
+		host = host[4:]
+	}
+	c.Host = joinHost(host, port)
+	if r&HTTPS != 0 && scheme == "http" && port == "" {
+		c.Scheme = "https"
+	}
+	if r&Fragment != 0 {
+		c.Fragment, c.RawFragment = "", ""
+	}
+	if r&Slash != 0 {
+		// Trim the escaped path, so an escaped slash such as /a%2F stays
+		escaped := strings.TrimRight(c.EscapedPath(), "/")
+		if path, err := url.PathUnescape(escaped); err == nil {
+			c.Path, c.RawPath = path, escaped
+		}
+	}
+	if r&Query != 0 {
+		c.RawQuery = sortQuery(c.RawQuery)
+		c.ForceQuery = false
+	}
+	return &c
+}
+
+// Reports whether u is an absolute http or https URL with a host
+func isWeb(u *url.URL) bool {
+	scheme := strings.ToLower(u.Scheme)
+	return (scheme == "http" || scheme == "https") && u.Host != "" && u.Opaque == ""
+}
+
+// Puts a host and an optional port back together, bracketing IPv6
+// addresses
+func joinHost(host, port string) string {
+	if port != "" {
+		return net.JoinHostPort(host, port)
+	}
+	if strings.Contains(host, ":") {
+		return "[" + host + "]"
+	}
+	return host
+}
+
+// Sorts the parameters of a raw query by name without decoding them, so
+// their escaping survives
+func sortQuery(raw string) string {
+	params := []string{}
+	for _, param := range strings.Split(raw, "&") {
+		if param != "" {
+			params = append(params, param)
+		}
+	}
+	name := func(param string) string {
+		if idx := strings.IndexByte(param, '='); idx >= 0 {
+			return param[:idx]
+		}
+		return param
+	}
+	sort.SliceStable(params, func(i, j int) bool {
+		return name(params[i]) < name(params[j])
+	})
+	return strings.Join(params, "&")
+}
@@ -0,0 +1,121 @@
+package canonical
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestURL(t *testing.T) {
+	for _, tc := range []struct {
+		rules Rules
+		raw   string
+		want  string
+	}{
+		{None, "HTTP://WWW.Calpoly.EDU:80/About/?b=2&a=1#top", "HTTP://WWW.Calpoly.EDU:80/About/?b=2&a=1#top"},
+
+		{Fragment, "https://a.edu/x#top", "https://a.edu/x"},
+		{Fragment, "https://a.edu/x#", "https://a.edu/x"},
+
+		{Case, "HTTPS://WWW.Calpoly.EDU/About", "https://www.calpoly.edu/About"},
+
+		{Port, "http://a.edu:80/x", "http://a.edu/x"},
+		{Port, "https://a.edu:443/x", "https://a.edu/x"},
+		{Port, "http://a.edu:443/x", "http://a.edu:443/x"},
+		{Port, "https://a.edu:8443/x", "https://a.edu:8443/x"},
+
+		{Slash, "https://a.edu/atoz/", "https://a.edu/atoz"},
+		{Slash, "https://a.edu/", "https://a.edu"},
+		{Slash, "https://a.edu/a//", "https://a.edu/a"},
+		{Slash, "https://a.edu/a%2F/", "https://a.edu/a%2F"},
+
+		{Query, "https://a.edu/s?q=go&b=2&a=1&b=1&&", "https://a.edu/s?a=1&b=2&b=1&q=go"},
+		{Query, "https://a.edu/s?z=%20&a=%2F", "https://a.edu/s?a=%2F&z=%20"},
+		{Query, "https://a.edu/s?", "https://a.edu/s"},
+
+		{HTTPS, "http://a.edu/x", "https://a.edu/x"},
+		{HTTPS, "http://a.edu:8080/x", "http://a.edu:8080/x"},
+		{HTTPS, "http://a.edu:80/x", "http://a.edu:80/x"},
+		{Port | HTTPS, "http://a.edu:80/x", "https://a.edu/x"},
+
+		{WWW, "https://www.calpoly.edu/x", "https://calpoly.edu/x"},
+		{WWW, "https://WWW.calpoly.edu/x", "https://calpoly.edu/x"},
+		{WWW, "https://www.localhost/x", "https://www.localhost/x"},
+		{WWW, "https://www/x", "https://www/x"},
+		{WWW, "https://www.calpoly.edu:8443/x", "https://calpoly.edu:8443/x"},
+
+		// IPv6 hosts keep their brackets
+		{Default, "http://[::1]:80/x/", "https://[::1]/x"},
+		{Default, "https://[2001:DB8::1]:8443/", "https://[2001:db8::1]:8443"},
+		{Default | WWW, "http://[fe80::1]/", "https://[fe80::1]"},
+
+		{Default, "http://WWW.calpoly.edu:80/#primary", "https://www.calpoly.edu"},
+
+		// Anything but an absolute http or https URL is left alone
+		{Default | WWW, "42", "42"},
+		{Default | WWW, "mailto:a@b.edu", "mailto:a@b.edu"},
+		{Default | WWW, "ftp://www.a.edu/x/", "ftp://www.a.edu/x/"},
+		{Default | WWW, "/relative/path/#x", "/relative/path/#x"},
+		{Default | WWW, "httpfoo/", "httpfoo/"},
+		{Default | WWW, "http:opaque/", "http:opaque/"},
+		{Default | WWW, "https:///no/host/", "https:///no/host/"},
+		{Default | WWW, "http://a b.edu/", "http://a b.edu/"},
+	} {
+		if got := tc.rules.URL(tc.raw); got != tc.want {
+			t.Errorf("%v: URL(%q) = %q, want %q", tc.rules, tc.raw, got, tc.want)
+		}
+	}
+}
+
+func TestApplyCopies(t *testing.T) {
+	u, err := url.Parse("http://WWW.a.edu/x/#top")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := (Default | WWW).Apply(u).String(); got != "https://a.edu/x" {
+		t.Errorf("Apply = %q, want https://a.edu/x", got)
+	}
+	if u.String() != "http://WWW.a.edu/x/#top" {
+		t.Errorf("Apply changed its argument to %q", u)
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	for _, tc := range []struct {
+		list string
+		want Rules
+	}{
+		{"", None},
+		{"none", None},
+		{"default", Default},
+		{"default,www", Default | WWW},
+		{" Fragment , CASE ", Fragment | Case},
+		{"https,,port", HTTPS | Port},
+		{"www,none", WWW},
+	} {
+		got, err := ParseRules(tc.list)
+		if err != nil || got != tc.want {
+			t.Errorf("ParseRules(%q) = %v, %v, want %v", tc.list, got, err, tc.want)
+		}
+	}
+	for _, list := range []string{"bogus", "default,http"} {
+		if _, err := ParseRules(list); err == nil {
+			t.Errorf("ParseRules(%q) did not fail", list)
+		}
+	}
+}
+
+func TestRulesStringRoundTrip(t *testing.T) {
+	if None.String() != "none" {
+		t.Errorf("None is %q, want none", None.String())
+	}
+	if Default.String() != "fragment,case,port,slash,query,https" {
+		t.Errorf("Default is %q", Default.String())
+	}
+	all := Rules(1<<uint(len(ruleNames)) - 1)
+	for r := None; r <= all; r++ {
+		var back Rules
+		if err := back.Set(r.String()); err != nil || back != r {
+			t.Errorf("%q read back as %v, %v, want %v", r.String(), back, err, r)
+		}
+	}
+}
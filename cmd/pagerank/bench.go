@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+
+	"../../pagerank"
+)
+
+func runBench(args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	in := addInputFlags(fs)
+	eng := addEngineFlags(fs)
+	workers := fs.Int("workers", runtime.GOMAXPROCS(0), "goroutines in shared mode, partitions in parallel mode")
+	runs := fs.Int("runs", 3, "number of runs per mode")
+	solvers := fs.String("solvers", "jacobi,gauss-seidel,sor", "comma separated solvers to time in sequential mode")
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	if *runs < 1 {
+		return fmt.Errorf("need at least one run, found %d", *runs)
+	}
+
+	// The jacobi solver runs in every mode, the others only sequentially
+	type setup struct{ mode, solver string }
+	setups := []setup{}
+	for _, mode := range modes {
+		setups = append(setups, setup{mode, pagerank.SolverJacobi.String()})
+	}
+	for _, name := range strings.Split(*solvers, ",") {
+		solver, err := pagerank.ParseSolver(strings.TrimSpace(name))
+		if err != nil {
+			return err
+		}
+		if solver != pagerank.SolverJacobi {
+			setups = append(setups, setup{"sequential", solver.String()})
+		}
+	}
+
+	fmt.Printf("mode\tsolver\truns\titerations\tbest\tmean\n")
+	for _, s := range setups {
+		eng.solver = s.solver
+		var best, total time.Duration
+		iterations := 0
+		for i := 0; i < *runs; i++ {
+			r, err := rankGraph(in, eng, s.mode, *workers)
+			if err != nil {
+				return err
+			}
+			if i == 0 || r.elapsed < best {
+				best = r.elapsed
+			}
+			total += r.elapsed
+			iterations = r.iterations
+		}
+		fmt.Printf("%s\t%s\t%d\t%d\t%s\t%s\n", s.mode, s.solver, *runs, iterations, best, total/time.Duration(*runs))
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"../../pagerank"
+)
+
+func runCompare(args []string) error {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	top := fs.Int("top", 20, "compare the overlap of this many of the best ranked URLs, overall and per domain")
+	movers := fs.Int("movers", 10, "list this many of the URLs whose position changed the most")
+	domains := fs.Bool("domains", false, "print the top overlap of every domain")
+	metric := fs.String("metric", pagerank.MetricL1, "metric checked against -threshold: l1, l2, linf, tau, rho or precision")
+	threshold := fs.Float64("threshold", 0, "fail if the metric is above this distance, or below this correlation or precision")
+	if err := parse(fs, args, 2); err != nil {
+		return err
+	}
+	checked := false
+	fs.Visit(func(f *flag.Flag) {
+		checked = checked || f.Name == "threshold"
+	})
+	if _, _, err := (&pagerank.Comparison{}).Metric(*metric); err != nil {
+		return err
+	}
+	a, err := pagerank.ReadRowsFile(fs.Arg(0), "")
+	if err != nil {
+		return err
+	}
+	b, err := pagerank.ReadRowsFile(fs.Arg(1), "")
+	if err != nil {
+		return err
+	}
+
+	cmp := pagerank.Compare(a, b, *top)
+	fmt.Printf("URLs:\t%d\t%d\t%d common\n", cmp.SizeA, cmp.SizeB, cmp.Common)
+	fmt.Printf("L1:\t%g\n", cmp.L1)
+	fmt.Printf("L2:\t%g\n", cmp.L2)
+	fmt.Printf("Linf:\t%g\n", cmp.LInf)
+	fmt.Printf("Kendall tau:\t%f\n", cmp.Kendall)
+	fmt.Printf("Spearman rho:\t%f\n", cmp.Spearman)
+	if *top > 0 {
+		fmt.Printf("Precision@%d:\t%f\n", *top, cmp.Precision)
+		same := 0
+		for _, d := range cmp.Domains {
+			if d.Overlap == d.Size {
+				same++
+			}
+		}
+		fmt.Printf("Domains with the same top %d:\t%d of %d\n", *top, same, len(cmp.Domains))
+		if *domains {
+			for _, d := range cmp.Domains {
+				fmt.Printf("\t%s\t%d/%d\n", d.Domain, d.Overlap, d.Size)
+			}
+		}
+	}
+	if *movers > 0 && len(cmp.Movers) > 0 && cmp.Movers[0].Shift() != 0 {
+		fmt.Printf("Moved the most:\n")
+		for idx, m := range cmp.Movers {
+			if idx == *movers || m.Shift() == 0 {
+				break
+			}
+			fmt.Printf("\t%+d\t%d -> %d\t%s\t%g -> %g\n", m.Shift(), m.RankA, m.RankB, m.URL, m.ScoreA, m.ScoreB)
+		}
+	}
+
+	if checked {
+		exceeds, err := cmp.Exceeds(*metric, *threshold)
+		if err != nil {
+			return err
+		}
+		if exceeds {
+			value, _, _ := cmp.Metric(*metric)
+			return fmt.Errorf("%s %g is past the threshold %g", *metric, value, *threshold)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"flag"
+
+	"../../pagerank"
+)
+
+func runConvert(args []string) error {
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	in := addInputFlags(fs)
+	output := fs.String("output", "", "file to write, gzip compressed when it ends in .gz")
+	outFormat := fs.String("to", "", "output format (default from the extension)")
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	graph, err := pagerank.ReadGraph(in.input, in.format, in.canonical)
+	if err != nil {
+		return err
+	}
+	return pagerank.WriteFile(*output, *outFormat, graph)
+}
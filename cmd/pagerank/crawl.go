@@ -0,0 +1,127 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"../../canonical"
+	"../../crawler"
+	"../../pagerank"
+)
+
+// A flag that can be given more than once, collecting every value
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// Returns the comma separated items of every value of l
+func (l listFlag) items() []string {
+	items := []string{}
+	for _, value := range l {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
+
+// Compiles every value of l, which may hold commas, as one regular
+// expression
+func (l listFlag) patterns() ([]*regexp.Regexp, error) {
+	patterns := []*regexp.Regexp{}
+	for _, value := range l {
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, fmt.Errorf("bad scope pattern: %v", err)
+		}
+		patterns = append(patterns, re)
+	}
+	return patterns, nil
+}
+
+func runCrawl(args []string) error {
+	fs := flag.NewFlagSet("crawl", flag.ExitOnError)
+	seeds := fs.String("seeds", crawler.DefaultSeed, "comma separated URLs to start from")
+	seedFile := fs.String("seedfile", "", "file with one URL to start from per line, instead of the default -seeds")
+	output := fs.String("output", "calpoly.gv", "dot file to write")
+	cfg := crawler.Config{Progress: os.Stderr, Canonical: canonical.Default}
+	fs.StringVar(&cfg.Username, "u", "", "username for basic authentication")
+	fs.StringVar(&cfg.Password, "p", "", "password for basic authentication")
+	fs.Var(&cfg.Canonical, "canonical", canonicalUsage)
+	stripWWW := fs.Bool("stripwww", false, "remove www. from every link, short for adding www to -canonical")
+	fs.StringVar(&cfg.UserAgent, "agent", crawler.DefaultUserAgent, "User-agent sent and looked up in robots.txt")
+	fs.Float64Var(&cfg.HostRate, "rate", 2, "requests per second to any one host, 0 for no limit")
+	fs.BoolVar(&cfg.IgnoreRobots, "norobots", false, "ignore robots.txt and Crawl-delay")
+	fs.IntVar(&cfg.Fetchers, "fetchers", crawler.DefaultFetchers, "pages fetched at the same time")
+	strategy := fs.String("strategy", crawler.BreadthFirst.String(), "crawl order: bfs, depth-limited or best-first")
+	fs.IntVar(&cfg.MaxDepth, "maxdepth", 0, "links followed from a seed at most, 0 for no limit")
+	fs.IntVar(&cfg.MaxPages, "maxpages", 0, "pages crawled at most, 0 for no limit")
+	fs.IntVar(&cfg.MaxPagesPerHost, "maxhost", 0, "pages crawled per host at most, 0 for no limit")
+	var allowHosts, denyHosts, allowSuffixes, denySuffixes, allowPatterns, denyPatterns listFlag
+	fs.Var(&allowHosts, "allowhost", "comma separated hosts to crawl, repeatable")
+	fs.Var(&denyHosts, "denyhost", "comma separated hosts not to crawl, repeatable")
+	fs.Var(&allowSuffixes, "allowsuffix", "comma separated domains to crawl with every host below them, repeatable (default the registrable domains of the seeds)")
+	fs.Var(&denySuffixes, "denysuffix", "comma separated domains not to crawl, repeatable")
+	fs.Var(&allowPatterns, "allowregex", "regular expression of URLs to crawl, repeatable")
+	fs.Var(&denyPatterns, "denyregex", "regular expression of URLs not to crawl, repeatable")
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	if *stripWWW {
+		cfg.Canonical |= canonical.WWW
+	}
+	var err error
+	if cfg.Strategy, err = crawler.ParseStrategy(*strategy); err != nil {
+		return err
+	}
+	// A seed file replaces the default seed, but adds to explicit -seeds
+	list := *seeds
+	if *seedFile != "" {
+		list = ""
+		fs.Visit(func(f *flag.Flag) {
+			if f.Name == "seeds" {
+				list = *seeds
+			}
+		})
+	}
+	if cfg.Seeds, err = pagerank.CollectSeeds(list, *seedFile, "", nil, canonical.None); err != nil {
+		return err
+	}
+	cfg.Scope = crawler.Scope{
+		AllowHosts:    allowHosts.items(),
+		DenyHosts:     denyHosts.items(),
+		AllowSuffixes: allowSuffixes.items(),
+		DenySuffixes:  denySuffixes.items(),
+	}
+	if cfg.Scope.AllowPatterns, err = allowPatterns.patterns(); err != nil {
+		return err
+	}
+	if cfg.Scope.DenyPatterns, err = denyPatterns.patterns(); err != nil {
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	file, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := crawler.Crawl(cfg, file); err != nil {
+		return err
+	}
+	return file.Close()
+}
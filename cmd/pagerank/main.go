@@ -0,0 +1,171 @@
+// Command pagerank ranks, crawls, converts, compares and benchmarks link
+// graphs. Every parameter is a flag, so nothing needs editing in the
+// source to try a different damping factor or input file:
+//
+//	pagerank rank -input ./dot_files/auth.gv -damping 0.85 -top 20
+//	pagerank rank -mode parallel -workers 8 -output ranks.tsv
+//	pagerank crawl -output ./dot_files/calpoly.gv
+//	pagerank convert -input auth.gv -output auth.txt.gz
+//	pagerank compare seq.tsv par.tsv
+//	pagerank update -previous ranks.tsv -delta changes.txt
+//	pagerank walk -walks 100 -seed 7
+//	pagerank bench -runs 5
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"../../canonical"
+	"../../pagerank"
+)
+
+// A subcommand parses its own flags from args
+type command struct {
+	summary string
+	run     func(args []string) error
+}
+
+var commands = map[string]command{
+	"rank":    {"compute page rank of a graph", runRank},
+	"crawl":   {"crawl the web into a dot graph", runCrawl},
+	"convert": {"convert a graph between input formats", runConvert},
+	"compare": {"compare two ranking files", runCompare},
+	"update":  {"update a ranking after the graph changed", runUpdate},
+	"walk":    {"estimate page rank with random walks", runWalk},
+	"bench":   {"time the sequential and parallel engines", runBench},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: pagerank <command> [flags]\n\ncommands:\n")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun pagerank <command> -h for the flags of a command.\n")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		if os.Args[1] != "-h" && os.Args[1] != "help" {
+			fmt.Fprintf(os.Stderr, "pagerank: unknown command %q\n\n", os.Args[1])
+		}
+		usage()
+		os.Exit(2)
+	}
+	if err := cmd.run(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "pagerank %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+// Flags naming the input graph and how to read its URLs
+type inputFlags struct {
+	input     string
+	format    string
+	canonical canonical.Rules
+}
+
+func addInputFlags(fs *flag.FlagSet) *inputFlags {
+	f := &inputFlags{canonical: canonical.Default}
+	fs.StringVar(&f.input, "input", "./dot_files/auth.gv", "graph to read, optionally gzip compressed")
+	fs.StringVar(&f.format, "format", "", "input format: "+strings.Join(pagerank.Formats(), ", ")+" (default from the extension)")
+	fs.Var(&f.canonical, "canonical", canonicalUsage)
+	return f
+}
+
+// Usage of the -canonical flags of the readers and the crawler
+const canonicalUsage = "comma separated rules that rewrite URLs into canonical form: fragment, case, port, slash, query, https, www, default or none"
+
+// Flags controlling the page rank engine
+type engineFlags struct {
+	damping    float64
+	tolerance  float64
+	norm       string
+	maxIter    int
+	solver     string
+	omega      float64
+	float32    bool
+	links      string
+	seeds      string
+	seedFile   string
+	seedDomain string
+}
+
+func addEngineFlags(fs *flag.FlagSet) *engineFlags {
+	defaults := pagerank.DefaultOptions()
+	f := &engineFlags{}
+	fs.Float64Var(&f.damping, "damping", defaults.Damping, "damping factor d")
+	fs.Float64Var(&f.tolerance, "tol", defaults.Epsilon, "stop once the residual between iterations falls below this")
+	fs.StringVar(&f.norm, "norm", defaults.Norm.String(), "norm of the residual: l1, l2 or linf")
+	fs.IntVar(&f.maxIter, "maxiter", defaults.MaxIterations, "fail after this many iterations without converging, 0 for no limit")
+	fs.StringVar(&f.solver, "solver", pagerank.SolverJacobi.String(), "iteration of the sequential and shared modes: jacobi, gauss-seidel or sor")
+	fs.Float64Var(&f.omega, "omega", 1.1, "relaxation factor of the sor solver")
+	fs.BoolVar(&f.float32, "float32", false, "keep the rank vectors of the sequential and shared modes in float32 to save memory")
+	fs.StringVar(&f.links, "links", pagerank.LinksMultiple.String(), "parallel links: multiple counts each, unique merges them, weighted sums them and their weight attributes")
+	fs.StringVar(&f.seeds, "seeds", "", "comma separated seed URLs for personalized page rank")
+	fs.StringVar(&f.seedFile, "seedfile", "", "file with one seed URL per line")
+	fs.StringVar(&f.seedDomain, "seeddomain", "", "use every URL of this domain as seed, e.g. admissions")
+	return f
+}
+
+// Builds the options of the engine. nodes is needed for -seeddomain, and
+// the seed URLs are rewritten by rules like the URLs of the graph.
+func (f *engineFlags) options(nodes []string, rules canonical.Rules) (pagerank.Options, error) {
+	opts := pagerank.DefaultOptions()
+	if f.damping < 0 || f.damping > 1 {
+		return opts, fmt.Errorf("damping factor %g is not between 0 and 1", f.damping)
+	}
+	opts.Damping = f.damping
+	opts.Epsilon = f.tolerance
+	opts.MaxIterations = f.maxIter
+	norm, err := pagerank.ParseNorm(f.norm)
+	if err != nil {
+		return opts, err
+	}
+	opts.Norm = norm
+	solver, err := pagerank.ParseSolver(f.solver)
+	if err != nil {
+		return opts, err
+	}
+	if f.omega <= 0 || f.omega >= 2 {
+		return opts, fmt.Errorf("relaxation factor %g is not between 0 and 2", f.omega)
+	}
+	opts.Solver = solver
+	opts.Omega = f.omega
+	opts.Float32 = f.float32
+	if opts.Links, err = pagerank.ParseLinks(f.links); err != nil {
+		return opts, err
+	}
+	seeds, err := pagerank.CollectSeeds(f.seeds, f.seedFile, f.seedDomain, nodes, rules)
+	if err != nil {
+		return opts, err
+	}
+	if len(seeds) > 0 {
+		opts.Personalization = pagerank.Personalize(seeds)
+	}
+	return opts, nil
+}
+
+// Parses args, rejecting positional arguments beyond want
+func parse(fs *flag.FlagSet, args []string, want int) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != want {
+		return fmt.Errorf("expected %d arguments, found %d", want, fs.NArg())
+	}
+	return nil
+}
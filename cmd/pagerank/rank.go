@@ -0,0 +1,189 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+
+	"../../pagerank"
+)
+
+// The outcome of one page rank run
+type ranking struct {
+	ranks      pagerank.Ranks
+	iterations int
+	// Residual in the norm of -norm after every iteration
+	history []float64
+	// Time spent in the engine, without reading the graph
+	elapsed time.Duration
+	// The whole graph, nil in parallel mode
+	graph *pagerank.CSR
+}
+
+// Names of the engines rankGraph can run
+var modes = []string{"sequential", "shared", "parallel"}
+
+// Ranks the input graph. The sequential mode runs on the whole graph in
+// compressed sparse row form, the shared mode splits every iteration over
+// the node ranges of workers goroutines, and the parallel mode deals the
+// domains out over workers partitions that run in their own goroutines.
+// A *pagerank.ConvergenceError comes with the ranking of the last
+// iteration.
+func rankGraph(in *inputFlags, eng *engineFlags, mode string, workers int) (*ranking, error) {
+	switch mode {
+	case "sequential", "shared":
+		graph, err := pagerank.ReadCSR(in.input, in.format, in.canonical)
+		if err != nil {
+			return nil, err
+		}
+		opts, err := eng.options(graph.URLs, in.canonical)
+		if err != nil {
+			return nil, err
+		}
+		opts.Workers = 1
+		if mode == "shared" {
+			opts.Workers = workers
+		}
+		start := time.Now()
+		result, err := pagerank.ComputeCSR(graph, opts)
+		return &ranking{graph.Ranks(result.Ranks), result.Iterations, result.History, time.Since(start), graph}, err
+	case "parallel":
+		if workers < 1 {
+			return nil, fmt.Errorf("need at least one worker, found %d", workers)
+		}
+		domains, err := pagerank.GetDomains(in.input, in.format, in.canonical)
+		if err != nil {
+			return nil, err
+		}
+		owner := pagerank.NewOwner(domains, workers)
+		parts := make([]*pagerank.Partition, workers)
+		nodes := []string{}
+		for idx := range parts {
+			if parts[idx], err = pagerank.ReadPartition(in.input, in.format, in.canonical, owner, idx); err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, parts[idx].Graph.URLs...)
+		}
+		opts, err := eng.options(nodes, in.canonical)
+		if err != nil {
+			return nil, err
+		}
+		if opts.Solver != pagerank.SolverJacobi {
+			return nil, fmt.Errorf("the parallel mode only runs the %s solver", pagerank.SolverJacobi)
+		}
+		if opts.Links != pagerank.LinksMultiple {
+			return nil, fmt.Errorf("the parallel mode only ranks %s links", pagerank.LinksMultiple)
+		}
+		if opts.Float32 {
+			return nil, fmt.Errorf("the parallel mode only keeps its rank vectors in float64")
+		}
+		start := time.Now()
+		result, err := pagerank.ComputePartitions(parts, owner, opts)
+		return &ranking{result.Ranks, result.Iterations, result.History, time.Since(start), nil}, err
+	}
+	return nil, fmt.Errorf("unknown mode %q, want sequential, shared or parallel", mode)
+}
+
+func runRank(args []string) error {
+	fs := flag.NewFlagSet("rank", flag.ExitOnError)
+	in := addInputFlags(fs)
+	eng := addEngineFlags(fs)
+	mode := fs.String("mode", "sequential", "sequential, shared or parallel")
+	workers := fs.Int("workers", runtime.GOMAXPROCS(0), "goroutines in shared mode, partitions in parallel mode")
+	output := fs.String("output", "", "export the ranking to this file, - for standard output")
+	outFormat := fs.String("outformat", "", "ranking format: jsonl, csv, tsv or columnar (default from the extension, tsv for -)")
+	limit := fs.Int("limit", 0, "export only this many of the best ranked URLs, 0 for the full vector")
+	top := fs.Int("top", 20, "print this many of the best ranked URLs")
+	history := fs.Bool("history", false, "print the residual after every iteration")
+	hits := fs.Bool("hits", false, "also compute HITS hub and authority scores and export them next to the page rank")
+	query := fs.String("query", "", "run HITS on the base set of the URLs containing any of these comma separated terms")
+	queryLinks := fs.Int("querylinks", 50, "in-links of every root URL added to the -query base set, 0 for all")
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+
+	r, err := rankGraph(in, eng, *mode, *workers)
+	if r == nil {
+		return err
+	}
+	// A ranking that did not converge is still printed and exported, but
+	// the command fails afterwards
+	rankErr := err
+	if *history {
+		for idx, residual := range r.history {
+			fmt.Fprintf(os.Stderr, "%d\t%g\n", idx+1, residual)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Done after %d iterations in %s\n", r.iterations, r.elapsed)
+	if *top > 0 && *output != "-" {
+		if err := pagerank.WriteRanks(os.Stdout, r.ranks, *top); err != nil {
+			return err
+		}
+	}
+	if *output == "" && !*hits {
+		return rankErr
+	}
+	// HITS and the degrees of the export need the whole graph
+	if r.graph == nil {
+		if r.graph, err = pagerank.ReadCSR(in.input, in.format, in.canonical); err != nil {
+			return err
+		}
+	}
+	var hubs, authorities pagerank.Ranks
+	if *hits {
+		opts, err := eng.options(r.graph.URLs, in.canonical)
+		if err != nil {
+			return err
+		}
+		opts.Workers = *workers
+		graph := r.graph
+		if *query != "" {
+			graph = pagerank.BaseSet(graph, pagerank.MatchURLs(graph.URLs, *query), *queryLinks)
+			fmt.Fprintf(os.Stderr, "Query base set of %d nodes and %d links\n", graph.NumNodes(), graph.NumEdges())
+		}
+		result, err := pagerank.HITS(graph, opts)
+		if result == nil {
+			return err
+		}
+		if rankErr == nil {
+			rankErr = err
+		}
+		fmt.Fprintf(os.Stderr, "HITS done after %d iterations\n", result.Iterations)
+		hubs, authorities = graph.Ranks(result.Hubs), graph.Ranks(result.Authorities)
+		if *top > 0 && *output != "-" {
+			fmt.Printf("\nHubs:\n")
+			if err := pagerank.WriteRanks(os.Stdout, hubs, *top); err != nil {
+				return err
+			}
+			fmt.Printf("\nAuthorities:\n")
+			if err := pagerank.WriteRanks(os.Stdout, authorities, *top); err != nil {
+				return err
+			}
+		}
+	}
+	if *output == "" {
+		return rankErr
+	}
+	rows := pagerank.Rows(r.ranks, r.graph, *limit)
+	if *hits {
+		pagerank.SetHITS(rows, hubs, authorities)
+	}
+	if err := export(*output, *outFormat, rows); err != nil {
+		return err
+	}
+	return rankErr
+}
+
+// Writes rows to path in the given ranking format, or as tab separated
+// values to standard output when path is -
+func export(path, format string, rows []pagerank.Row) error {
+	if path != "-" {
+		return pagerank.WriteRowsFile(path, format, rows)
+	}
+	if format == "" {
+		format = pagerank.RankFormatTSV
+	}
+	return pagerank.WriteRows(os.Stdout, format, rows)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"../../pagerank"
+)
+
+func runUpdate(args []string) error {
+	fs := flag.NewFlagSet("update", flag.ExitOnError)
+	in := addInputFlags(fs)
+	eng := addEngineFlags(fs)
+	previous := fs.String("previous", "", "ranking of the input graph, in any ranking format")
+	deltaFile := fs.String("delta", "", "file of \"+|- src [dest]\" lines to apply to the input graph")
+	target := fs.String("to", "", "changed graph to diff the input graph against, instead of -delta")
+	targetFormat := fs.String("toformat", "", "input format of -to (default from the extension)")
+	save := fs.String("save", "", "write the changed graph to this file")
+	output := fs.String("output", "", "export the updated ranking to this file, - for standard output")
+	outFormat := fs.String("outformat", "", "ranking format: jsonl, csv, tsv or columnar (default from the extension, tsv for -)")
+	limit := fs.Int("limit", 0, "export only this many of the best ranked URLs, 0 for the full vector")
+	top := fs.Int("top", 20, "print this many of the best ranked URLs")
+	verify := fs.Bool("verify", false, "also rank the changed graph from scratch and print the L1 distance")
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+	if *previous == "" || (*deltaFile == "") == (*target == "") {
+		return fmt.Errorf("need -previous and one of -delta or -to")
+	}
+
+	graph, err := pagerank.ReadGraph(in.input, in.format, in.canonical)
+	if err != nil {
+		return err
+	}
+	ranks, err := pagerank.ReadRanks(*previous)
+	if err != nil {
+		return err
+	}
+	var delta pagerank.Delta
+	if *deltaFile != "" {
+		if delta, err = pagerank.ReadDelta(*deltaFile, in.canonical); err != nil {
+			return err
+		}
+		graph.Apply(delta)
+	} else {
+		changed, err := pagerank.ReadGraph(*target, *targetFormat, in.canonical)
+		if err != nil {
+			return err
+		}
+		delta = pagerank.Diff(graph, changed)
+		graph = changed
+	}
+	if *save != "" {
+		if err := pagerank.WriteFile(*save, "", graph); err != nil {
+			return err
+		}
+	}
+
+	c := pagerank.NewCSR(graph)
+	opts, err := eng.options(c.URLs, in.canonical)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	result, updateErr := pagerank.Update(c, ranks, delta, opts)
+	elapsed := time.Since(start)
+	if result.Recomputed {
+		fmt.Fprintf(os.Stderr, "Recomputed from the previous ranking after %d iterations in %s\n", result.Iterations, elapsed)
+	} else {
+		fmt.Fprintf(os.Stderr, "Updated with %d pushes touching %d of %d nodes in %s\n",
+			result.Pushes, result.Touched, c.NumNodes(), elapsed)
+	}
+	if *verify {
+		full, err := pagerank.ComputeCSR(c, opts)
+		if err != nil {
+			return err
+		}
+		distance := 0.0
+		for id := range full.Ranks {
+			distance += math.Abs(full.Ranks[id] - result.Ranks[id])
+		}
+		fmt.Fprintf(os.Stderr, "L1 distance to ranking from scratch: %g\n", distance)
+	}
+
+	updated := c.Ranks(result.Ranks)
+	if *top > 0 && *output != "-" {
+		if err := pagerank.WriteRanks(os.Stdout, updated, *top); err != nil {
+			return err
+		}
+	}
+	if *output != "" {
+		if err := export(*output, *outFormat, pagerank.Rows(updated, c, *limit)); err != nil {
+			return err
+		}
+	}
+	return updateErr
+}
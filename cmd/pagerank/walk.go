@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+
+	"../../pagerank"
+)
+
+func runWalk(args []string) error {
+	fs := flag.NewFlagSet("walk", flag.ExitOnError)
+	in := addInputFlags(fs)
+	eng := addEngineFlags(fs)
+	walks := fs.Int("walks", 100, "random walks launched from every node")
+	seed := fs.Int64("seed", 1, "seed of the random walks; the same seed gives the same estimate")
+	workers := fs.Int("workers", runtime.GOMAXPROCS(0), "goroutines the walks run on")
+	exact := fs.Bool("exact", true, "also run the power iteration and report the error of the estimate")
+	output := fs.String("output", "", "export the estimated ranking to this file, - for standard output")
+	outFormat := fs.String("outformat", "", "ranking format: jsonl, csv, tsv or columnar (default from the extension, tsv for -)")
+	limit := fs.Int("limit", 0, "export only this many of the best ranked URLs, 0 for the full vector")
+	top := fs.Int("top", 20, "print this many of the best ranked URLs and compare their overlap")
+	if err := parse(fs, args, 0); err != nil {
+		return err
+	}
+
+	graph, err := pagerank.ReadCSR(in.input, in.format, in.canonical)
+	if err != nil {
+		return err
+	}
+	opts, err := eng.options(graph.URLs, in.canonical)
+	if err != nil {
+		return err
+	}
+	opts.Workers = *workers
+	start := time.Now()
+	result, err := pagerank.RandomWalks(graph, opts, *walks, *seed)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Estimated from %d walks of %d steps in %s\n", result.Walks, result.Steps, time.Since(start))
+	estimate := graph.Ranks(result.Ranks)
+
+	if *exact {
+		start = time.Now()
+		power, err := pagerank.ComputeCSR(graph, opts)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Power iteration done after %d iterations in %s\n", power.Iterations, time.Since(start))
+		cmp := pagerank.Compare(pagerank.Rows(estimate, graph, 0), pagerank.Rows(graph.Ranks(power.Ranks), graph, 0), *top)
+		fmt.Fprintf(os.Stderr, "L1:\t%g\n", cmp.L1)
+		fmt.Fprintf(os.Stderr, "Linf:\t%g\n", cmp.LInf)
+		fmt.Fprintf(os.Stderr, "Kendall tau:\t%f\n", cmp.Kendall)
+		if *top > 0 {
+			fmt.Fprintf(os.Stderr, "Precision@%d:\t%f\n", *top, cmp.Precision)
+		}
+	}
+
+	if *top > 0 && *output != "-" {
+		if err := pagerank.WriteRanks(os.Stdout, estimate, *top); err != nil {
+			return err
+		}
+	}
+	if *output != "" {
+		return export(*output, *outFormat, pagerank.Rows(estimate, graph, *limit))
+	}
+	return nil
+}
@@ -0,0 +1,294 @@
+// Copyright © 2016 The Go Programming Language
+// License: https://creativecommons.org/licenses/by-nc-sa/4.0/
+
+// Package crawler crawls the web on a pool of fetchers and writes the
+// links it finds as a dot graph, which the pagerank package reads.
+package crawler
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"../canonical"
+)
+
+// DefaultSeed is the page the crawler starts from unless told otherwise
+const DefaultSeed = "https://www.calpoly.edu"
+
+// DefaultUserAgent is the User-agent the crawler sends and looks up in
+// robots.txt files unless told otherwise
+const DefaultUserAgent = "ConcurrentPageRank/1.0"
+
+// DefaultFetchers is the number of pages fetched at the same time unless
+// told otherwise
+const DefaultFetchers = 8
+
+// Config controls a crawl
+type Config struct {
+	// URLs the crawl starts from
+	Seeds []string
+	// Links the crawl keeps. Crawl replaces an empty scope with
+	// SeedScope, which stays on the registrable domains of the seeds.
+	Scope Scope
+	// Credentials for basic authentication, used when Username is set
+	Username string
+	Password string
+	// Rules turning the seeds and every link into the canonical URL that
+	// names its node, so the crawl fetches and writes each page once.
+	// Pages are still fetched at the URL they were found at, so a rule
+	// such as canonical.HTTPS does not make http only hosts unreachable.
+	// None names the nodes by the URLs as they are found.
+	Canonical canonical.Rules
+	// Every URL is printed here before it is fetched, unless nil
+	Progress io.Writer
+	// User-agent of the requests, DefaultUserAgent when empty
+	UserAgent string
+	// Requests per second sent to any one host, zero for no limit. A
+	// larger Crawl-delay in the robots.txt of the host wins.
+	HostRate float64
+	// Fetch pages that robots.txt disallows, and skip the Crawl-delay
+	IgnoreRobots bool
+	// Client sending the requests, http.DefaultClient when nil
+	Client *http.Client
+	// Number of pages fetched at the same time, DefaultFetchers when zero
+	Fetchers int
+	// Order in which the frontier is crawled
+	Strategy Strategy
+	// Links followed from a seed at most. Pages further away are still
+	// written as link targets, but not crawled. Zero for no limit,
+	// except with DepthLimited, which needs one.
+	MaxDepth int
+	// Pages crawled at most, overall and per host, zero for no limit
+	MaxPages        int
+	MaxPagesPerHost int
+}
+
+// quote a url as a dot ID, so spaces and ';' in it survive parsing
+func quoteID(url string) string {
+	return "\"" + strings.Replace(url, "\"", "\\\"", -1) + "\""
+}
+
+// write a node statement recording how many links away from a seed the
+// crawl found url
+func writeNode(w *bufio.Writer, url string, depth int) {
+	fmt.Fprintf(w, "%s [depth=%d];\n", quoteID(url), depth)
+}
+
+// write to the dot graph with origin_url and all the urls it points to
+func writeLinks(w *bufio.Writer, origin_url string, url_list []string) {
+	for _, url := range url_list {
+		// write each link with original url and the new url it links
+		fmt.Fprintf(w, "%s -> %s;\n", quoteID(origin_url), quoteID(url))
+	}
+}
+
+// Result is what a fetcher found on one page
+type Result struct {
+	// The canonical URL of the crawled page and its distance in links
+	// from a seed when it was handed to the fetcher
+	Origin string
+	Depth  int
+	// Every link on the page that is part of the crawl, as found
+	Links []string
+	// Why the page could not be crawled, nil if it was
+	Err error
+}
+
+// ErrDisallowed is the error of pages that robots.txt disallows
+var ErrDisallowed = errors.New("disallowed by robots.txt")
+
+// Fetches the pages sent on jobs until it is closed, and sends what it
+// finds on results
+func fetcher(cfg Config, polite *Politeness, jobs <-chan *frontierItem, results chan<- Result) {
+	for item := range jobs {
+		r := Result{Origin: item.url, Depth: item.depth}
+		if polite.Allowed(item.fetch) {
+			polite.Wait(item.fetch)
+			cfg.progress("%s", item.fetch)
+			r.Links, r.Err = Extract(item.fetch, cfg)
+		} else {
+			r.Err = fmt.Errorf("%s: %w", item.fetch, ErrDisallowed)
+		}
+		results <- r
+	}
+}
+
+// crawlFrontier crawls the URLs of the frontier on cfg.Fetchers
+// fetchers and writes the links of each page to w as soon as it comes
+// back. URLs whose host already had cfg.MaxPagesPerHost pages crawled
+// are dropped, and the crawl winds down once cfg.MaxPages pages were
+// handed out. Only this goroutine touches the frontier, so the fetchers
+// share nothing but the two channels.
+//
+// The fetchers return pages in any order, so a link may first be found
+// on a page further from a seed than the shortest path to it. The depth
+// of every URL is therefore the shortest distance over the pages crawled
+// so far, and is lowered as shorter paths come back: a queued URL moves
+// up the frontier, a URL that was past cfg.MaxDepth joins it once it is
+// not, and a crawled page passes the shorter distance on to its links.
+// Every change writes the node statement of the URL again, and since
+// the last attributes of a node win, the file always holds the depths
+// known so far.
+func crawlFrontier(cfg Config, polite *Politeness, queue *frontier, w *bufio.Writer) {
+	// Shortest known distance of every URL from a seed
+	depth := make(map[string]int)
+	for _, item := range queue.items {
+		depth[item.url] = item.depth
+		writeNode(w, item.url, item.depth)
+	}
+	// Pages handed to a fetcher, and the links of those that came back
+	fetched := make(map[string]bool)
+	links := make(map[string][]string)
+	// The URL every canonical URL was first found at, which is fetched
+	found := make(map[string]string)
+	for _, item := range queue.items {
+		found[item.url] = item.fetch
+	}
+	// Records that url is d links away from a seed, and passes a shorter
+	// distance on as described above
+	reach := func(url string, d int) {
+		type step struct {
+			url   string
+			depth int
+		}
+		steps := []step{{url, d}}
+		for len(steps) > 0 {
+			next := steps[len(steps)-1]
+			steps = steps[:len(steps)-1]
+			if old, ok := depth[next.url]; ok && old <= next.depth {
+				continue
+			}
+			depth[next.url] = next.depth
+			writeNode(w, next.url, next.depth)
+			switch {
+			case fetched[next.url]:
+				for _, link := range links[next.url] {
+					steps = append(steps, step{link, next.depth + 1})
+				}
+			case queue.lower(next.url, next.depth):
+			case cfg.MaxDepth < 1 || next.depth <= cfg.MaxDepth:
+				queue.push(next.url, found[next.url], next.depth)
+			}
+		}
+	}
+	crawled := 0
+	perHost := make(map[string]int)
+	// Reports whether the limits let the crawl fetch item
+	admit := func(item *frontierItem) bool {
+		if cfg.MaxPagesPerHost < 1 {
+			return true
+		}
+		u, err := url.Parse(item.fetch)
+		return err != nil || perHost[u.Host] < cfg.MaxPagesPerHost
+	}
+	fetchers := cfg.Fetchers
+	if fetchers < 1 {
+		fetchers = DefaultFetchers
+	}
+	jobs := make(chan *frontierItem)
+	results := make(chan Result)
+	for i := 0; i < fetchers; i++ {
+		go fetcher(cfg, polite, jobs, results)
+	}
+	defer close(jobs)
+
+	pending := 0 // pages handed to a fetcher that have not come back
+	for {
+		if cfg.MaxPages > 0 && crawled >= cfg.MaxPages {
+			queue = newFrontier(cfg.Strategy)
+		}
+		for queue.Len() > 0 && !admit(queue.peek()) {
+			queue.pop()
+		}
+		if queue.Len() == 0 && pending == 0 {
+			return
+		}
+		// Only offer a job while there is one
+		var send chan<- *frontierItem
+		var next *frontierItem
+		if queue.Len() > 0 {
+			send, next = jobs, queue.peek()
+		}
+		select {
+		case send <- next:
+			queue.pop()
+			fetched[next.url] = true
+			pending++
+			crawled++
+			if u, err := url.Parse(next.fetch); err == nil {
+				perHost[u.Host]++
+			}
+		case r := <-results:
+			pending--
+			if r.Err != nil {
+				cfg.progress("%v", r.Err)
+			}
+			// The origin may have been found closer to a seed while it
+			// was being fetched
+			d := depth[r.Origin]
+			linked := make(map[string]bool)
+			nodes := make([]string, len(r.Links))
+			for idx, raw := range r.Links {
+				link := cfg.Canonical.URL(raw)
+				nodes[idx] = link
+				if linked[link] {
+					continue
+				}
+				linked[link] = true
+				if _, ok := found[link]; !ok {
+					found[link] = raw
+				}
+				links[r.Origin] = append(links[r.Origin], link)
+				reach(link, d+1)
+				queue.linked(link)
+			}
+			writeLinks(w, r.Origin, nodes) // write new connections in form "origin -> url"
+			w.Flush()                      // keep what we have if the crawl is killed
+		}
+	}
+}
+
+// Validate reports settings of cfg that cannot work together
+func (cfg Config) Validate() error {
+	if len(cfg.Seeds) == 0 {
+		return errors.New("no seed URLs to crawl from")
+	}
+	if cfg.Strategy == DepthLimited && cfg.MaxDepth < 1 {
+		return fmt.Errorf("the %s strategy needs a maximum depth", DepthLimited)
+	}
+	return nil
+}
+
+// Crawl crawls the web from cfg.Seeds in the order of cfg.Strategy and
+// writes every link it finds to w as a dot graph, naming every page by
+// the canonical form of cfg.Canonical. Every URL comes with a
+// depth attribute, the fewest links the crawl followed from a seed to
+// find it; a URL gets another node statement whenever a shorter path
+// turns up, and the last one holds its depth. Pages that fail to
+// load are logged to cfg.Progress and skipped. So are pages the
+// robots.txt of their host disallows, and the requests to every host
+// are spaced out as Politeness describes.
+func Crawl(cfg Config, w io.Writer) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	if cfg.Scope.Empty() {
+		cfg.Scope = SeedScope(cfg.Seeds)
+	}
+	writer := bufio.NewWriter(w)
+	writer.WriteString("digraph {\n")
+	queue := newFrontier(cfg.Strategy)
+	for _, seed := range cfg.Seeds {
+		if node := cfg.Canonical.URL(seed); queue.queued[node] == nil {
+			queue.push(node, seed, 0)
+		}
+	}
+	crawlFrontier(cfg, NewPoliteness(cfg), queue, writer)
+	writer.WriteString("}\n")
+	return writer.Flush()
+}
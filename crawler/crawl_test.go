@@ -0,0 +1,160 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"../canonical"
+)
+
+// A page of a test site: how long it takes to serve and where it links
+type testPage struct {
+	delay time.Duration
+	links []string
+}
+
+// Starts a site serving pages by path and returns it with the set of
+// paths that were fetched
+func siteServer(t *testing.T, pages map[string]testPage) (*httptest.Server, func() map[string]bool) {
+	t.Helper()
+	var mu sync.Mutex
+	fetched := make(map[string]bool)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		mu.Lock()
+		fetched[r.URL.Path] = true
+		mu.Unlock()
+		time.Sleep(page.delay)
+		for _, link := range page.links {
+			fmt.Fprintf(w, "<a href=%q>link</a>\n", link)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() map[string]bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return fetched
+	}
+}
+
+var nodeRE = regexp.MustCompile(`(?m)^"([^"]*)" \[depth=(\d+)\];$`)
+
+// Returns the depth of every URL in a crawl, taken from its last node
+// statement
+func depths(dot string) map[string]string {
+	depth := make(map[string]string)
+	for _, m := range nodeRE.FindAllStringSubmatch(dot, -1) {
+		depth[m[1]] = m[2]
+	}
+	return depth
+}
+
+func TestCrawlShortestDepth(t *testing.T) {
+	// /z is two links away through the slow /a, and three through /b
+	// and /c, which come back first. /y is one link past /z.
+	pages := map[string]testPage{
+		"/":  {links: []string{"/a", "/b"}},
+		"/a": {delay: 300 * time.Millisecond, links: []string{"/z"}},
+		"/b": {links: []string{"/c"}},
+		"/c": {links: []string{"/z"}},
+		"/z": {links: []string{"/y"}},
+		"/y": {},
+	}
+	for _, tc := range []struct {
+		maxDepth int
+		strategy Strategy
+		crawled  []string
+		skipped  []string
+	}{
+		{maxDepth: 2, strategy: BreadthFirst, crawled: []string{"/", "/a", "/b", "/c", "/z"}, skipped: []string{"/y"}},
+		{maxDepth: 2, strategy: DepthLimited, crawled: []string{"/", "/a", "/b", "/c", "/z"}, skipped: []string{"/y"}},
+		{maxDepth: 0, strategy: BreadthFirst, crawled: []string{"/", "/a", "/b", "/c", "/z", "/y"}},
+	} {
+		srv, fetched := siteServer(t, pages)
+		var out bytes.Buffer
+		cfg := Config{
+			Seeds:        []string{srv.URL + "/"},
+			Client:       srv.Client(),
+			IgnoreRobots: true,
+			Fetchers:     2,
+			Strategy:     tc.strategy,
+			MaxDepth:     tc.maxDepth,
+		}
+		if err := Crawl(cfg, &out); err != nil {
+			t.Fatal(err)
+		}
+		name := fmt.Sprintf("%s, max depth %d", tc.strategy, tc.maxDepth)
+		for _, path := range tc.crawled {
+			if !fetched()[path] {
+				t.Errorf("%s: %s was not crawled", name, path)
+			}
+		}
+		for _, path := range tc.skipped {
+			if fetched()[path] {
+				t.Errorf("%s: %s was crawled past the maximum depth", name, path)
+			}
+		}
+		want := map[string]string{"/": "0", "/a": "1", "/b": "1", "/c": "2", "/z": "2", "/y": "3"}
+		got := depths(out.String())
+		for path, d := range want {
+			if got[srv.URL+path] != d {
+				t.Errorf("%s: depth of %s is %q, want %s", name, path, got[srv.URL+path], d)
+			}
+		}
+	}
+}
+
+func TestCrawlFetchesURLsAsFound(t *testing.T) {
+	pages := map[string]testPage{
+		"/":          {links: []string{"/dir/", "/dir/#top", "/dir?b=2&a=1"}},
+		"/dir/":      {links: []string{"/", "/dir/page/"}},
+		"/dir":       {links: []string{"/dir/"}},
+		"/dir/page/": {},
+	}
+	srv, fetched := siteServer(t, pages)
+	// Send every host to the server, which only speaks http
+	addr := srv.Listener.Addr().String()
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}}
+	var out bytes.Buffer
+	cfg := Config{
+		Seeds:        []string{"http://www.example.edu/"},
+		Client:       client,
+		IgnoreRobots: true,
+		Canonical:    canonical.Default,
+	}
+	if err := Crawl(cfg, &out); err != nil {
+		t.Fatal(err)
+	}
+	// The pages are fetched over http and /dir/ with its slash, as found
+	for _, path := range []string{"/", "/dir/", "/dir/page/"} {
+		if !fetched()[path] {
+			t.Errorf("%s was not fetched", path)
+		}
+	}
+	const node = "https://www.example.edu"
+	want := map[string]string{node: "0", node + "/dir": "1", node + "/dir?a=1&b=2": "1", node + "/dir/page": "2"}
+	if got := depths(out.String()); !reflect.DeepEqual(got, want) {
+		t.Errorf("nodes %v, want %v", got, want)
+	}
+	if !strings.Contains(out.String(), fmt.Sprintf("%q -> %q;", node+"/dir", node)) {
+		t.Errorf("link from /dir/ to / is not written with canonical names:\n%s", out.String())
+	}
+}
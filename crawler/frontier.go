@@ -0,0 +1,159 @@
+package crawler
+
+import (
+	"container/heap"
+	"fmt"
+	"strings"
+)
+
+// Strategy selects which URL of the frontier is crawled next
+type Strategy int
+
+const (
+	// BreadthFirst crawls the URLs closest to a seed first, in the order
+	// they were found, so all pages one link away from the seeds come
+	// before those two links away
+	BreadthFirst Strategy = iota
+	// DepthLimited crawls the most recently found URL first, following
+	// one path of links down to Config.MaxDepth before backing up
+	DepthLimited
+	// BestFirst crawls the URL with the most in-links from the pages
+	// crawled so far first, which reaches the important pages of a site
+	// early when the crawl is cut short
+	BestFirst
+)
+
+var strategyNames = []string{"bfs", "depth-limited", "best-first"}
+
+func (s Strategy) String() string {
+	if s < 0 || int(s) >= len(strategyNames) {
+		return fmt.Sprintf("Strategy(%d)", int(s))
+	}
+	return strategyNames[s]
+}
+
+// ParseStrategy returns the strategy called name: bfs, depth-limited or
+// best-first
+func ParseStrategy(name string) (Strategy, error) {
+	for s, strategyName := range strategyNames {
+		if name == strategyName {
+			return Strategy(s), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown strategy %q, want one of %s", name, strings.Join(strategyNames, ", "))
+}
+
+// A URL waiting in the frontier
+type frontierItem struct {
+	// Canonical URL naming the page, and the URL it was found at, which
+	// is fetched
+	url   string
+	fetch string
+	// Links between a seed and the URL, zero for the seeds
+	depth int
+	// Number of crawled pages linking to the URL
+	inLinks int
+	// Order in which the URL was found
+	seq int
+	// Position in the heap, kept up to date for heap.Fix
+	index int
+}
+
+// frontier is a priority queue of the URLs that are still to be crawled,
+// ordered by its strategy
+type frontier struct {
+	strategy Strategy
+	items    []*frontierItem
+	// The queued items by URL, so BestFirst can raise their in-links
+	queued map[string]*frontierItem
+	seq    int
+}
+
+func newFrontier(strategy Strategy) *frontier {
+	return &frontier{strategy: strategy, queued: make(map[string]*frontierItem)}
+}
+
+// push queues the page named url, found at fetch depth links away from a
+// seed
+func (f *frontier) push(url, fetch string, depth int) {
+	item := &frontierItem{url: url, fetch: fetch, depth: depth, seq: f.seq}
+	f.seq++
+	f.queued[url] = item
+	heap.Push(f, item)
+}
+
+// pop removes and returns the URL that is crawled next
+func (f *frontier) pop() *frontierItem {
+	item := heap.Pop(f).(*frontierItem)
+	delete(f.queued, item.url)
+	return item
+}
+
+// peek returns the URL that is crawled next without removing it
+func (f *frontier) peek() *frontierItem {
+	return f.items[0]
+}
+
+// lower moves url to depth if it is queued, and reports whether it was
+func (f *frontier) lower(url string, depth int) bool {
+	item, ok := f.queued[url]
+	if !ok {
+		return false
+	}
+	item.depth = depth
+	heap.Fix(f, item.index)
+	return true
+}
+
+// linked records another crawled page that links to url, if it is queued
+func (f *frontier) linked(url string) {
+	if item, ok := f.queued[url]; ok {
+		item.inLinks++
+		if f.strategy == BestFirst {
+			heap.Fix(f, item.index)
+		}
+	}
+}
+
+// Len, Less, Swap, Push and Pop implement heap.Interface
+
+func (f *frontier) Len() int {
+	return len(f.items)
+}
+
+func (f *frontier) Less(i, j int) bool {
+	a, b := f.items[i], f.items[j]
+	switch f.strategy {
+	case BreadthFirst:
+		if a.depth != b.depth {
+			return a.depth < b.depth
+		}
+	case DepthLimited:
+		return a.seq > b.seq
+	case BestFirst:
+		if a.inLinks != b.inLinks {
+			return a.inLinks > b.inLinks
+		}
+	}
+	return a.seq < b.seq
+}
+
+func (f *frontier) Swap(i, j int) {
+	f.items[i], f.items[j] = f.items[j], f.items[i]
+	f.items[i].index = i
+	f.items[j].index = j
+}
+
+func (f *frontier) Push(x interface{}) {
+	item := x.(*frontierItem)
+	item.index = len(f.items)
+	f.items = append(f.items, item)
+}
+
+func (f *frontier) Pop() interface{} {
+	last := len(f.items) - 1
+	item := f.items[last]
+	f.items[last] = nil
+	f.items = f.items[:last]
+	return item
+}
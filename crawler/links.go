@@ -1,24 +1,28 @@
-// Copyright © 2016 The Go Programming Language 
+// Copyright © 2016 The Go Programming Language
 // License: https://creativecommons.org/licenses/by-nc-sa/4.0/
 
-//!+Extract
-
-// Package links provides a link-extraction function.
-package links
+package crawler
 
 import (
 	"fmt"
 	"net/http"
 	"strings"
-	"regexp"
+
 	"golang.org/x/net/html"
 )
 
 // Extract makes an HTTP GET request to the specified URL, parses
 // the response as HTML, and returns the links in the HTML document.
-func Extract(url, username, passwd string) ([]string, error) {
-
-	resp, err := http.Get(url)
+// The request is sent by cfg.Client with the user agent of cfg, and uses
+// basic authentication when cfg has a username. Only the links in
+// cfg.Scope are returned, as they are found, so they can be fetched;
+// the crawl turns them into canonical node names itself.
+func Extract(url string, cfg Config) ([]string, error) {
+	req, err := cfg.request(url)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := cfg.client().Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -45,15 +49,12 @@ func Extract(url, username, passwd string) ([]string, error) {
 				if err != nil {
 					continue // ignore bad URLs
 				}
-				// only save url if it is in the calpoly.edu domain
+				// only save url if it is in the scope of the crawl, and
+				// skip redirects that carry a second url
 				link_str := link.String()
-				regex := regexp.MustCompile("http")
-				num_instances := len(regex.FindAllStringIndex(link_str, -1))
-				
-				if strings.Contains(link_str, "calpoly.edu") && num_instances == 1 {
-					if strings.Contains(link_str, "#") {
-						link_str = strings.Split(link_str, "#")[0]
-					}
+				num_instances := strings.Count(link_str, "http")
+
+				if cfg.Scope.Contains(link) && num_instances == 1 {
 					links = append(links, link_str)
 				}
 			}
@@ -63,8 +64,6 @@ func Extract(url, username, passwd string) ([]string, error) {
 	return links, nil
 }
 
-//!-Extract
-
 func forEachNode(n *html.Node, pre, post func(n *html.Node)) {
 	if pre != nil {
 		pre(n)
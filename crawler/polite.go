@@ -0,0 +1,205 @@
+package crawler
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Largest robots.txt file read, the limit RFC 9309 asks crawlers to
+// support at least
+const maxRobotsSize = 500 << 10
+
+// Politeness keeps a crawl from overloading the hosts it visits. It
+// fetches the robots.txt file of every host once, and spaces out the
+// requests to each host by the larger of the configured rate and the
+// Crawl-delay of the host. robots.txt applies to one scheme and host,
+// while the spacing applies to the host whatever the scheme, so http
+// and https requests to a server share its rate.
+type Politeness struct {
+	cfg    Config
+	mu     sync.Mutex
+	robots map[string]*robotsState
+	slots  map[string]*hostSlot
+}
+
+// The robots.txt rules of a scheme and host
+type robotsState struct {
+	once   sync.Once
+	robots *Robots
+}
+
+// When a host may be visited next
+type hostSlot struct {
+	mu   sync.Mutex
+	next time.Time
+}
+
+// NewPoliteness returns the politeness rules of a crawl with cfg
+func NewPoliteness(cfg Config) *Politeness {
+	return &Politeness{
+		cfg:    cfg,
+		robots: make(map[string]*robotsState),
+		slots:  make(map[string]*hostSlot),
+	}
+}
+
+// Returns the robots.txt state of the scheme and host of u, creating
+// it on first sight
+func (p *Politeness) robotsState(u *url.URL) *robotsState {
+	key := strings.ToLower(u.Scheme + "://" + u.Host)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	r, ok := p.robots[key]
+	if !ok {
+		r = &robotsState{}
+		p.robots[key] = r
+	}
+	return r
+}
+
+// Returns the slot of the host of u, creating it on first sight. The
+// key leaves out the scheme and its default port, so http://a.edu and
+// https://a.edu:443 are one host.
+func (p *Politeness) slot(u *url.URL) *hostSlot {
+	key := strings.ToLower(u.Hostname())
+	if port := u.Port(); port != "" && !(port == "80" && strings.EqualFold(u.Scheme, "http")) &&
+		!(port == "443" && strings.EqualFold(u.Scheme, "https")) {
+		key = net.JoinHostPort(key, port)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s, ok := p.slots[key]
+	if !ok {
+		s = &hostSlot{}
+		p.slots[key] = s
+	}
+	return s
+}
+
+// Robots returns the robots.txt rules of the host of u, fetching the
+// file the first time the host is asked about. A missing file allows
+// everything and a server error disallows everything. Every other
+// failure, such as an unreachable host, allows everything, so the page
+// request reports the error instead.
+func (p *Politeness) Robots(u *url.URL) *Robots {
+	r := p.robotsState(u)
+	r.once.Do(func() {
+		r.robots = p.fetchRobots(u)
+	})
+	return r.robots
+}
+
+func (p *Politeness) fetchRobots(u *url.URL) *Robots {
+	if p.cfg.IgnoreRobots {
+		return AllowAll
+	}
+	robotsURL := &url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/robots.txt"}
+	req, err := p.cfg.request(robotsURL.String())
+	if err != nil {
+		return AllowAll
+	}
+	resp, err := p.cfg.client().Do(req)
+	if err != nil {
+		p.cfg.progress("robots.txt of %s: %v", u.Host, err)
+		return AllowAll
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 500:
+		p.cfg.progress("robots.txt of %s: %s, not crawling the host", u.Host, resp.Status)
+		return DisallowAll
+	case resp.StatusCode != http.StatusOK:
+		return AllowAll
+	}
+	robots, err := ParseRobots(io.LimitReader(resp.Body, maxRobotsSize), p.cfg.userAgent())
+	if err != nil {
+		p.cfg.progress("robots.txt of %s: %v", u.Host, err)
+		return AllowAll
+	}
+	return robots
+}
+
+// Allowed reports whether the robots.txt file of its host lets the
+// crawl fetch rawURL. URLs that do not parse are allowed, so the page
+// request reports the error.
+func (p *Politeness) Allowed(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return true
+	}
+	path := u.EscapedPath()
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return p.Robots(u).Allowed(path)
+}
+
+// Wait blocks until the host of rawURL may be sent the next request.
+// Concurrent callers for the same host are given consecutive slots, so
+// they leave at the configured rate instead of all at once, whether
+// they ask for http or https URLs.
+func (p *Politeness) Wait(rawURL string) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return
+	}
+	interval := p.Robots(u).CrawlDelay
+	if p.cfg.HostRate > 0 {
+		if gap := time.Duration(float64(time.Second) / p.cfg.HostRate); gap > interval {
+			interval = gap
+		}
+	}
+	s := p.slot(u)
+	s.mu.Lock()
+	now := time.Now()
+	slot := s.next
+	if slot.Before(now) {
+		slot = now
+	}
+	s.next = slot.Add(interval)
+	s.mu.Unlock()
+	time.Sleep(time.Until(slot))
+}
+
+// Returns the HTTP client of the crawl
+func (cfg Config) client() *http.Client {
+	if cfg.Client != nil {
+		return cfg.Client
+	}
+	return http.DefaultClient
+}
+
+// Returns the User-agent the crawl sends and matches robots.txt against
+func (cfg Config) userAgent() string {
+	if cfg.UserAgent != "" {
+		return cfg.UserAgent
+	}
+	return DefaultUserAgent
+}
+
+// Returns a GET request for rawURL with the user agent and credentials
+// of the crawl
+func (cfg Config) request(rawURL string) (*http.Request, error) {
+	req, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", cfg.userAgent())
+	if cfg.Username != "" {
+		req.SetBasicAuth(cfg.Username, cfg.Password)
+	}
+	return req, nil
+}
+
+// Prints a line to cfg.Progress unless it is nil
+func (cfg Config) progress(format string, args ...interface{}) {
+	if cfg.Progress != nil {
+		fmt.Fprintf(cfg.Progress, format+"\n", args...)
+	}
+}
@@ -0,0 +1,147 @@
+package crawler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Starts a server that answers robots.txt with robots, or with status
+// when it is not 200, and counts how often it was asked for the file
+func robotsServer(t *testing.T, status int, robots string) (*httptest.Server, *int) {
+	t.Helper()
+	fetches := 0
+	var mu sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/robots.txt" {
+			fmt.Fprint(w, "<html></html>")
+			return
+		}
+		mu.Lock()
+		fetches++
+		mu.Unlock()
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		fmt.Fprint(w, robots)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &fetches
+}
+
+func TestPolitenessRobots(t *testing.T) {
+	ok, okFetches := robotsServer(t, http.StatusOK, "User-agent: *\nDisallow: /private\n")
+	missing, _ := robotsServer(t, http.StatusNotFound, "")
+	broken, _ := robotsServer(t, http.StatusServiceUnavailable, "")
+	polite := NewPoliteness(Config{Client: ok.Client()})
+	for _, tc := range []struct {
+		url  string
+		want bool
+	}{
+		{ok.URL + "/", true},
+		{ok.URL + "/private/a", false},
+		{ok.URL + "/public", true},
+		{missing.URL + "/private/a", true},
+		{broken.URL + "/", false},
+		{"http://127.0.0.1:1/unreachable", true},
+	} {
+		if got := polite.Allowed(tc.url); got != tc.want {
+			t.Errorf("Allowed(%s) = %v, want %v", tc.url, got, tc.want)
+		}
+	}
+	if *okFetches != 1 {
+		t.Errorf("robots.txt fetched %d times, want once", *okFetches)
+	}
+
+	ignoring := NewPoliteness(Config{Client: ok.Client(), IgnoreRobots: true})
+	if !ignoring.Allowed(ok.URL + "/private/a") {
+		t.Error("IgnoreRobots still disallowed a page")
+	}
+}
+
+func mustParse(t *testing.T, rawURL string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+// Calls Wait for rawURL from calls goroutines at once and returns the
+// times they were let through, in order
+func waitTimes(polite *Politeness, rawURL string, calls int) []time.Time {
+	times := make([]time.Time, calls)
+	var wg sync.WaitGroup
+	for idx := range times {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			polite.Wait(rawURL)
+			times[idx] = time.Now()
+		}(idx)
+	}
+	wg.Wait()
+	for i := 1; i < len(times); i++ {
+		for j := i; j > 0 && times[j].Before(times[j-1]); j-- {
+			times[j], times[j-1] = times[j-1], times[j]
+		}
+	}
+	return times
+}
+
+// Fails unless consecutive times are at least gap apart
+func checkGaps(t *testing.T, name string, times []time.Time, gap time.Duration) {
+	t.Helper()
+	// Timers may fire a little early on a busy machine
+	slack := gap / 10
+	for i := 1; i < len(times); i++ {
+		if d := times[i].Sub(times[i-1]); d < gap-slack {
+			t.Errorf("%s: requests %d and %d were %v apart, want at least %v", name, i-1, i, d, gap)
+		}
+	}
+}
+
+func TestPolitenessWait(t *testing.T) {
+	delayed, _ := robotsServer(t, http.StatusOK, "User-agent: *\nCrawl-delay: 0.15\n")
+	plain, _ := robotsServer(t, http.StatusOK, "User-agent: *\nDisallow:\n")
+
+	// The rate sets the gap unless the Crawl-delay is longer
+	polite := NewPoliteness(Config{Client: plain.Client(), HostRate: 20})
+	checkGaps(t, "rate", waitTimes(polite, plain.URL+"/a", 4), 50*time.Millisecond)
+	checkGaps(t, "crawl delay", waitTimes(polite, delayed.URL+"/a", 3), 150*time.Millisecond)
+
+	// Hosts do not wait for each other
+	polite = NewPoliteness(Config{Client: plain.Client(), HostRate: 2})
+	polite.Robots(mustParse(t, plain.URL))
+	polite.Robots(mustParse(t, delayed.URL))
+	start := time.Now()
+	polite.Wait(plain.URL + "/a")
+	polite.Wait(delayed.URL + "/a")
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("first requests to two hosts took %v, want no wait", elapsed)
+	}
+
+	// http and https requests to one host share its slots, whatever the
+	// scheme and its default port
+	polite = NewPoliteness(Config{IgnoreRobots: true, HostRate: 20})
+	times := []time.Time{}
+	for _, rawURL := range []string{"http://a.example/x", "https://a.example/y", "HTTPS://A.example:443/z", "http://a.example:80/w"} {
+		polite.Wait(rawURL)
+		times = append(times, time.Now())
+	}
+	checkGaps(t, "schemes", times, 50*time.Millisecond)
+
+	// Without a rate or Crawl-delay nothing waits
+	polite = NewPoliteness(Config{Client: plain.Client()})
+	start = time.Now()
+	waitTimes(polite, plain.URL+"/a", 5)
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("unlimited requests took %v, want no wait", elapsed)
+	}
+}
@@ -0,0 +1,156 @@
+package crawler
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Robots holds the rules of a robots.txt file that apply to one user
+// agent
+type Robots struct {
+	rules []robotsRule
+	// Time to wait between two requests, zero when the file sets none
+	CrawlDelay time.Duration
+}
+
+// An Allow or Disallow line. The pattern may hold * wildcards and end in
+// $ to match the end of the path.
+type robotsRule struct {
+	pattern string
+	allow   bool
+}
+
+// AllowAll is the rule set of a host without a robots.txt file
+var AllowAll = &Robots{}
+
+// DisallowAll is the rule set of a host whose robots.txt file could not
+// be fetched because of a server error, which RFC 9309 asks crawlers to
+// treat as a complete disallow
+var DisallowAll = &Robots{rules: []robotsRule{{pattern: "/", allow: false}}}
+
+// ParseRobots reads a robots.txt file and keeps the group of rules for
+// agent, or the group for * when no group names agent. Agents match
+// when the User-agent line is a case insensitive prefix of agent's
+// product token, so "User-agent: concurrent" applies to
+// "ConcurrentPageRank/1.0". Unknown lines and malformed values are
+// skipped, like every crawler does.
+func ParseRobots(r io.Reader, agent string) (*Robots, error) {
+	token := strings.ToLower(agent)
+	if idx := strings.IndexAny(token, "/ "); idx >= 0 {
+		token = token[:idx]
+	}
+	type group struct {
+		agents []string
+		robots Robots
+	}
+	groups := []*group{}
+	var current *group
+	// Consecutive User-agent lines share one group
+	inAgents := false
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if idx := strings.IndexByte(line, '#'); idx >= 0 {
+			line = line[:idx]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		if key == "user-agent" {
+			if !inAgents {
+				current = &group{}
+				groups = append(groups, current)
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+			inAgents = true
+			continue
+		}
+		inAgents = false
+		if current == nil {
+			continue
+		}
+		switch key {
+		case "allow", "disallow":
+			// An empty Disallow allows everything
+			if value != "" {
+				current.robots.rules = append(current.robots.rules, robotsRule{value, key == "allow"})
+			}
+		case "crawl-delay":
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds >= 0 {
+				current.robots.CrawlDelay = time.Duration(seconds * float64(time.Second))
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	var fallback *Robots
+	for _, g := range groups {
+		for _, name := range g.agents {
+			if name == "*" {
+				if fallback == nil {
+					fallback = &g.robots
+				}
+			} else if token != "" && strings.HasPrefix(token, name) {
+				return &g.robots, nil
+			}
+		}
+	}
+	if fallback == nil {
+		return AllowAll, nil
+	}
+	return fallback, nil
+}
+
+// Allowed reports whether path, including its query, may be crawled.
+// The rule with the longest matching pattern wins and Allow wins a tie,
+// as in RFC 9309.
+func (r *Robots) Allowed(path string) bool {
+	if path == "" {
+		path = "/"
+	}
+	allowed, longest := true, -1
+	for _, rule := range r.rules {
+		if !matchRobots(rule.pattern, path) {
+			continue
+		}
+		if len(rule.pattern) > longest || (len(rule.pattern) == longest && rule.allow) {
+			allowed, longest = rule.allow, len(rule.pattern)
+		}
+	}
+	return allowed
+}
+
+// Reports whether pattern matches the start of path, with * matching any
+// run of characters and a trailing $ the end of path
+func matchRobots(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	for _, part := range parts[1:] {
+		idx := strings.Index(rest, part)
+		if idx < 0 {
+			return false
+		}
+		rest = rest[idx+len(part):]
+	}
+	if !anchored {
+		return true
+	}
+	if rest == "" {
+		return true
+	}
+	// The last part has to end the path, so search for it from the end
+	last := parts[len(parts)-1]
+	return len(parts) > 1 && strings.HasSuffix(path, last)
+}
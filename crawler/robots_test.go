@@ -0,0 +1,95 @@
+package crawler
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const testRobots = `# Rules for every crawler
+User-agent: *
+Disallow: /private/
+Allow: /private/open
+Disallow: /*.pdf$
+Disallow: /search*q=
+Crawl-delay: 0.5
+
+User-agent: ConcurrentPageRank
+User-agent: other
+Disallow: /tmp
+Allow: /tmp/keep
+Disallow: /
+Allow: /$
+Allow: /public
+Crawl-delay: 2
+`
+
+func TestRobotsAllowed(t *testing.T) {
+	for _, tc := range []struct {
+		agent string
+		paths map[string]bool
+		delay time.Duration
+	}{
+		{
+			agent: "SomeBot/2.0",
+			delay: 500 * time.Millisecond,
+			paths: map[string]bool{
+				"/":                   true,
+				"/private/":           false,
+				"/private/secret":     false,
+				"/private/open":       true,
+				"/private/openings":   true,
+				"/papers/a.pdf":       false,
+				"/papers/a.pdf?x=1":   true,
+				"/search?q=go":        false,
+				"/search?page=2&q=go": false,
+				"/search?page=2":      true,
+			},
+		},
+		{
+			agent: "ConcurrentPageRank/1.0",
+			delay: 2 * time.Second,
+			paths: map[string]bool{
+				"/":           true,
+				"":            true,
+				"/index.html": false,
+				"/public/a":   true,
+				"/tmp/a":      false,
+				"/tmp/keep/a": true,
+			},
+		},
+	} {
+		robots, err := ParseRobots(strings.NewReader(testRobots), tc.agent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if robots.CrawlDelay != tc.delay {
+			t.Errorf("%s: crawl delay %v, want %v", tc.agent, robots.CrawlDelay, tc.delay)
+		}
+		for path, want := range tc.paths {
+			if got := robots.Allowed(path); got != want {
+				t.Errorf("%s: Allowed(%q) = %v, want %v", tc.agent, path, got, want)
+			}
+		}
+	}
+}
+
+func TestRobotsAllowWinsTie(t *testing.T) {
+	robots, err := ParseRobots(strings.NewReader("User-agent: *\nDisallow: /page\nAllow: /page\n"), "bot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !robots.Allowed("/page") {
+		t.Error("Disallow won a tie with an Allow of the same length")
+	}
+}
+
+func TestRobotsWithoutGroup(t *testing.T) {
+	robots, err := ParseRobots(strings.NewReader("User-agent: otherbot\nDisallow: /\n"), "ConcurrentPageRank/1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if robots != AllowAll {
+		t.Error("a file without a group for the agent or * did not allow everything")
+	}
+}
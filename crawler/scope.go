@@ -0,0 +1,97 @@
+package crawler
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"../registrable"
+)
+
+// Scope decides which links the crawl keeps. A link is kept when no deny
+// rule matches it and either an allow rule matches it or there are no
+// allow rules at all. Only http and https links are ever kept.
+type Scope struct {
+	// Hosts matched exactly, such as "www.calpoly.edu"
+	AllowHosts []string
+	DenyHosts  []string
+	// Domain suffixes, matching the domain itself and every host below
+	// it, so "calpoly.edu" matches ceng.calpoly.edu but not
+	// notcalpoly.edu
+	AllowSuffixes []string
+	DenySuffixes  []string
+	// Regular expressions matched against the whole URL
+	AllowPatterns []*regexp.Regexp
+	DenyPatterns  []*regexp.Regexp
+}
+
+// Empty reports whether s has no rules
+func (s Scope) Empty() bool {
+	return len(s.AllowHosts)+len(s.DenyHosts)+len(s.AllowSuffixes)+len(s.DenySuffixes)+
+		len(s.AllowPatterns)+len(s.DenyPatterns) == 0
+}
+
+// SeedScope returns the scope of a crawl that stays on the registrable
+// domains of seeds, which is what the crawler does without any rules:
+// a crawl from https://www.calpoly.edu keeps every link below
+// calpoly.edu.
+func SeedScope(seeds []string) Scope {
+	s := Scope{}
+	seen := make(map[string]bool)
+	for _, seed := range seeds {
+		u, err := url.Parse(seed)
+		if err != nil || u.Hostname() == "" {
+			continue
+		}
+		domain := registrable.Domain(u.Hostname())
+		if !seen[domain] {
+			seen[domain] = true
+			s.AllowSuffixes = append(s.AllowSuffixes, domain)
+		}
+	}
+	return s
+}
+
+// Contains reports whether the crawl keeps link
+func (s Scope) Contains(link *url.URL) bool {
+	if link.Scheme != "http" && link.Scheme != "https" {
+		return false
+	}
+	host := strings.TrimSuffix(strings.ToLower(link.Hostname()), ".")
+	raw := link.String()
+	if matchHost(s.DenyHosts, host) || matchSuffix(s.DenySuffixes, host) || matchPattern(s.DenyPatterns, raw) {
+		return false
+	}
+	if len(s.AllowHosts)+len(s.AllowSuffixes)+len(s.AllowPatterns) == 0 {
+		return true
+	}
+	return matchHost(s.AllowHosts, host) || matchSuffix(s.AllowSuffixes, host) || matchPattern(s.AllowPatterns, raw)
+}
+
+func matchHost(hosts []string, host string) bool {
+	for _, h := range hosts {
+		if strings.EqualFold(h, host) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchSuffix(suffixes []string, host string) bool {
+	for _, suffix := range suffixes {
+		suffix = strings.ToLower(strings.TrimPrefix(suffix, "."))
+		if host == suffix || strings.HasSuffix(host, "."+suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchPattern(patterns []*regexp.Regexp, raw string) bool {
+	for _, re := range patterns {
+		if re.MatchString(raw) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,38 @@
+package pagerank
+
+import (
+	"errors"
+	"testing"
+
+	"../canonical"
+)
+
+func TestBlockRankStopsAtMaxIterations(t *testing.T) {
+	graph, err := ReadCSR(writeGraph(t, "crawl.gv", testDot), "", canonical.Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := DefaultOptions()
+	opts.Epsilon = 0
+	opts.MaxIterations = 5
+	result, err := BlockRank(graph, opts)
+	var convergence *ConvergenceError
+	if !errors.As(err, &convergence) {
+		t.Fatalf("got error %v, want a *ConvergenceError", err)
+	}
+	if result.Iterations != 5 || len(result.History) != 5 || convergence.Iterations != 5 {
+		t.Errorf("block graph ran %d iterations, error reports %d, want 5", result.Iterations, convergence.Iterations)
+	}
+	if len(result.Start) != graph.NumNodes() {
+		t.Errorf("start has %d values, want %d", len(result.Start), graph.NumNodes())
+	}
+
+	opts = DefaultOptions()
+	opts.Norm = NormLInf
+	if result, err = BlockRank(graph, opts); err != nil {
+		t.Fatal(err)
+	}
+	if last := result.History[len(result.History)-1]; last >= opts.Epsilon {
+		t.Errorf("converged with a linf residual of %g, above %g", last, opts.Epsilon)
+	}
+}
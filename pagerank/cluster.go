@@ -0,0 +1,382 @@
+package pagerank
+
+import (
+	"fmt"
+	"net"
+	"net/rpc"
+	"sync"
+
+	"../canonical"
+)
+
+// Worker serves one partition of the distributed page rank computation
+// over net/rpc. Each superstep the coordinator calls Scatter, Update and
+// Finish on every worker in turn; during Scatter the workers send the
+// rank flowing across partitions straight to each other with Deliver.
+type Worker struct {
+	mu sync.Mutex
+	// Partition owned by this worker
+	part *Partition
+	// Index of this worker and the addresses of all workers
+	self  int
+	peers []string
+	// Connections to the other workers, opened on first use
+	clients []*rpc.Client
+	owner   Owner
+	opts    Options
+	// Closed by Stop to shut the worker down
+	done chan struct{}
+}
+
+// LoadArgs tells a worker which partition to read
+type LoadArgs struct {
+	Path   string
+	Format string
+	// Canonicalization rules of the coordinator, so every worker reads
+	// the URLs the same way
+	Canonical canonical.Rules
+	Owner     Owner
+	Self      int
+	Peers     []string
+	Opts      Options
+}
+
+// LoadReply reports the size of the partition a worker read
+type LoadReply struct {
+	Nodes           int
+	Personalization float64
+	Start           float64
+}
+
+// InitArgs holds the totals over all partitions
+type InitArgs struct {
+	Nodes           int
+	Personalization float64
+	Start           float64
+}
+
+// DeliverArgs holds rank sent from one worker to another, keyed by URL
+type DeliverArgs struct {
+	Rank map[string]float64
+}
+
+// ScatterReply holds the rank of a worker's dangling nodes
+type ScatterReply struct {
+	Dangling float64
+}
+
+// UpdateArgs holds the rank of the dangling nodes of all workers
+type UpdateArgs struct {
+	Dangling float64
+}
+
+// UpdateReply holds the sum of a worker's new page rank values
+type UpdateReply struct {
+	Sum float64
+}
+
+// FinishArgs holds the value every new page rank value is divided by
+type FinishArgs struct {
+	Scale float64
+}
+
+// FinishReply holds the partial residual between a worker's iterations
+type FinishReply struct {
+	Distance float64
+}
+
+// RanksReply holds the page rank values of a worker's nodes
+type RanksReply struct {
+	Ranks map[string]float64
+}
+
+// Empty is used for RPC calls without arguments or results
+type Empty struct{}
+
+// Load reads the worker's partition of the dot file
+func (w *Worker) Load(args *LoadArgs, reply *LoadReply) error {
+	part, err := ReadPartition(args.Path, args.Format, args.Canonical, args.Owner, args.Self)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.part = part
+	w.self = args.Self
+	w.peers = args.Peers
+	w.clients = make([]*rpc.Client, len(args.Peers))
+	w.owner = args.Owner
+	w.opts = args.Opts
+	reply.Nodes = part.NumOwned()
+	reply.Personalization, reply.Start = part.Prepare(args.Opts)
+	return nil
+}
+
+// Init sets the starting page rank values
+func (w *Worker) Init(args *InitArgs, reply *Empty) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.part.Init(args.Nodes, args.Personalization, args.Start, w.opts)
+	return nil
+}
+
+// Returns a connection to worker idx
+func (w *Worker) client(idx int) (*rpc.Client, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.clients[idx] == nil {
+		client, err := rpc.Dial("tcp", w.peers[idx])
+		if err != nil {
+			return nil, err
+		}
+		w.clients[idx] = client
+	}
+	return w.clients[idx], nil
+}
+
+// Scatter sends the rank flowing out of the partition to the workers
+// that own the destinations
+func (w *Worker) Scatter(args *Empty, reply *ScatterReply) error {
+	outgoing, dangling := w.part.Scatter()
+	// Group the outgoing rank by the worker that owns it
+	batches := make([]map[string]float64, len(w.peers))
+	for url, value := range outgoing {
+		idx := w.owner.Of(url)
+		if batches[idx] == nil {
+			batches[idx] = make(map[string]float64)
+		}
+		batches[idx][url] = value
+	}
+	var wg sync.WaitGroup
+	errs := make([]error, len(w.peers))
+	for idx, batch := range batches {
+		if batch == nil || idx == w.self {
+			continue
+		}
+		wg.Add(1)
+		go func(idx int, batch map[string]float64) {
+			defer wg.Done()
+			client, err := w.client(idx)
+			if err == nil {
+				err = client.Call("Worker.Deliver", &DeliverArgs{Rank: batch}, &Empty{})
+			}
+			errs[idx] = err
+		}(idx, batch)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	reply.Dangling = dangling
+	return nil
+}
+
+// Deliver receives rank sent by another worker
+func (w *Worker) Deliver(args *DeliverArgs, reply *Empty) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.part.Receive(args.Rank)
+	return nil
+}
+
+// Update computes the next page rank values of the partition
+func (w *Worker) Update(args *UpdateArgs, reply *UpdateReply) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	reply.Sum = w.part.Update(w.opts.Damping, args.Dangling)
+	return nil
+}
+
+// Finish completes the superstep
+func (w *Worker) Finish(args *FinishArgs, reply *FinishReply) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	reply.Distance = w.part.Finish(args.Scale)
+	return nil
+}
+
+// Ranks returns the page rank values of the partition
+func (w *Worker) Ranks(args *Empty, reply *RanksReply) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	reply.Ranks = w.part.OwnedRanks()
+	return nil
+}
+
+// Stop shuts the worker down once the reply has been sent
+func (w *Worker) Stop(args *Empty, reply *Empty) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, client := range w.clients {
+		if client != nil {
+			client.Close()
+		}
+	}
+	close(w.done)
+	return nil
+}
+
+// ServeWorker listens on addr and serves a Worker until the coordinator
+// calls Stop
+func ServeWorker(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return ServeWorkerListener(listener)
+}
+
+// ServeWorkerListener is like ServeWorker on a listener that is already
+// open, such as one on port 0 whose address is only known afterwards
+func ServeWorkerListener(listener net.Listener) error {
+	w := &Worker{done: make(chan struct{})}
+	server := rpc.NewServer()
+	if err := server.Register(w); err != nil {
+		listener.Close()
+		return err
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.ServeConn(conn)
+		}
+	}()
+	<-w.done
+	return listener.Close()
+}
+
+// Calls call for every worker at the same time and waits for all of them
+func callAll(clients []*rpc.Client, call func(idx int, client *rpc.Client) error) error {
+	var wg sync.WaitGroup
+	errs := make([]error, len(clients))
+	for idx, client := range clients {
+		wg.Add(1)
+		go func(idx int, client *rpc.Client) {
+			defer wg.Done()
+			errs[idx] = call(idx, client)
+		}(idx, client)
+	}
+	wg.Wait()
+	for idx, err := range errs {
+		if err != nil {
+			return fmt.Errorf("worker %d: %v", idx, err)
+		}
+	}
+	return nil
+}
+
+// Coordinate runs page rank on the graph at path, in the given input
+// format and with the URLs rewritten by rules, with one partition per
+// worker address. The domains of the graph are dealt out over the
+// workers, and supersteps are run until the residual over all workers
+// falls below opts.Epsilon or opts.MaxIterations is reached, in which
+// case the result comes with a *ConvergenceError. The workers are
+// stopped afterwards.
+func Coordinate(path, format string, rules canonical.Rules, workers []string, opts Options) (*Result, error) {
+	domains, err := GetDomains(path, format, rules)
+	if err != nil {
+		return nil, err
+	}
+	clients := make([]*rpc.Client, len(workers))
+	for idx, addr := range workers {
+		client, err := rpc.Dial("tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+		defer client.Close()
+		clients[idx] = client
+	}
+	defer callAll(clients, func(idx int, client *rpc.Client) error {
+		return client.Call("Worker.Stop", &Empty{}, &Empty{})
+	})
+
+	// Every worker reads its own partition
+	owner := NewOwner(domains, len(workers))
+	loads := make([]LoadReply, len(workers))
+	err = callAll(clients, func(idx int, client *rpc.Client) error {
+		args := LoadArgs{Path: path, Format: format, Canonical: rules, Owner: owner, Self: idx, Peers: workers, Opts: opts}
+		return client.Call("Worker.Load", &args, &loads[idx])
+	})
+	if err != nil {
+		return nil, err
+	}
+	init := InitArgs{}
+	for _, load := range loads {
+		init.Nodes += load.Nodes
+		init.Personalization += load.Personalization
+		init.Start += load.Start
+	}
+	err = callAll(clients, func(idx int, client *rpc.Client) error {
+		return client.Call("Worker.Init", &init, &Empty{})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{}
+	for {
+		scatters := make([]ScatterReply, len(workers))
+		err = callAll(clients, func(idx int, client *rpc.Client) error {
+			return client.Call("Worker.Scatter", &Empty{}, &scatters[idx])
+		})
+		if err != nil {
+			return nil, err
+		}
+		update := UpdateArgs{}
+		for _, scatter := range scatters {
+			update.Dangling += scatter.Dangling
+		}
+		updates := make([]UpdateReply, len(workers))
+		err = callAll(clients, func(idx int, client *rpc.Client) error {
+			return client.Call("Worker.Update", &update, &updates[idx])
+		})
+		if err != nil {
+			return nil, err
+		}
+		// Normalize because we want the sum of probabilities to equal one
+		finish := FinishArgs{Scale: 1}
+		if opts.Dangling == DanglingRescale {
+			finish.Scale = 0
+			for _, update := range updates {
+				finish.Scale += update.Sum
+			}
+		}
+		finishes := make([]FinishReply, len(workers))
+		err = callAll(clients, func(idx int, client *rpc.Client) error {
+			return client.Call("Worker.Finish", &finish, &finishes[idx])
+		})
+		if err != nil {
+			return nil, err
+		}
+		result.Iterations++
+		distances := make([]float64, len(finishes))
+		for idx, finish := range finishes {
+			distances[idx] = finish.Distance
+		}
+		residual := opts.Norm.Combine(distances)
+		result.History = append(result.History, residual)
+		if opts.converged(residual) || opts.reachedMax(result.Iterations) {
+			break
+		}
+	}
+
+	replies := make([]RanksReply, len(workers))
+	err = callAll(clients, func(idx int, client *rpc.Client) error {
+		return client.Call("Worker.Ranks", &Empty{}, &replies[idx])
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Ranks = Ranks{}
+	for _, reply := range replies {
+		for url, value := range reply.Ranks {
+			result.Ranks[url] = value
+		}
+	}
+	return result, opts.convergenceErr(result.History)
+}
@@ -0,0 +1,84 @@
+package pagerank
+
+import (
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"../canonical"
+)
+
+// A small crawl over three domains with links between them and a
+// dangling page
+const testDot = `digraph {
+	"https://a.example.edu" -> "https://a.example.edu/x";
+	"https://a.example.edu" -> "https://b.example.edu";
+	"https://a.example.edu/x" -> "https://a.example.edu";
+	"https://a.example.edu/x" -> "https://c.example.edu/y";
+	"https://b.example.edu" -> "https://b.example.edu/z";
+	"https://b.example.edu" -> "https://a.example.edu";
+	"https://b.example.edu/z" -> "https://c.example.edu";
+	"https://c.example.edu" -> "https://c.example.edu/y";
+	"https://c.example.edu" -> "https://a.example.edu/x";
+	"https://c.example.edu/y" -> "https://c.example.edu/dead";
+}
+`
+
+// Writes content to a file called name in a temporary directory and
+// returns its path
+func writeGraph(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// Starts n workers on localhost ports and returns their addresses
+func startWorkers(t *testing.T, n int) []string {
+	t.Helper()
+	addrs := make([]string, n)
+	for idx := range addrs {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		addrs[idx] = listener.Addr().String()
+		go ServeWorkerListener(listener)
+	}
+	return addrs
+}
+
+func TestCoordinateMatchesSequential(t *testing.T) {
+	path := writeGraph(t, "crawl.gv", testDot)
+	graph, err := ReadCSR(path, "", canonical.Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	personalized := DefaultOptions()
+	personalized.Personalization = Personalize([]string{"https://b.example.edu"})
+	for _, workers := range []int{2, 3} {
+		for name, opts := range map[string]Options{"uniform": DefaultOptions(), "personalized": personalized} {
+			opts.Epsilon = 1e-12
+			want, err := ComputeCSR(graph, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Coordinate(path, "", canonical.Default, startWorkers(t, workers), opts)
+			if err != nil {
+				t.Fatalf("%d workers, %s: %v", workers, name, err)
+			}
+			if len(got.Ranks) != graph.NumNodes() {
+				t.Errorf("%d workers, %s: got %d ranks, want %d", workers, name, len(got.Ranks), graph.NumNodes())
+			}
+			for id, url := range graph.URLs {
+				if diff := math.Abs(got.Ranks[url] - want.Ranks[id]); diff > 1e-9 {
+					t.Errorf("%d workers, %s: rank of %s is %g, want %g", workers, name, url, got.Ranks[url], want.Ranks[id])
+				}
+			}
+		}
+	}
+}
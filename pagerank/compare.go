@@ -0,0 +1,395 @@
+package pagerank
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Names of the metrics of a Comparison
+const (
+	MetricL1        = "l1"
+	MetricL2        = "l2"
+	MetricLInf      = "linf"
+	MetricKendall   = "tau"
+	MetricSpearman  = "rho"
+	MetricPrecision = "precision"
+)
+
+// Comparison measures how far apart two rankings of the same graph are
+type Comparison struct {
+	// Number of URLs in each ranking and in both
+	SizeA, SizeB, Common int
+	// Distances between the score vectors over the union of both
+	// rankings, where a missing URL scores zero
+	L1, L2, LInf float64
+	// Rank correlation of the scores of the common URLs, NaN if there
+	// are fewer than two
+	Kendall, Spearman float64
+	// Length of the top lists and the share of the top K of a that is
+	// also in the top K of b. When a ranking has fewer than K URLs the
+	// share is of the shorter top list, so identical rankings always
+	// score 1, and it is NaN when either ranking is empty.
+	K         int
+	Precision float64
+	// Top K overlap within every domain, sorted by domain
+	Domains []DomainOverlap
+	// Common URLs sorted by how far their position changed, furthest first
+	Movers []Move
+}
+
+// DomainOverlap is the number of URLs the top K lists of a domain share
+type DomainOverlap struct {
+	Domain string
+	// Length of the shorter top list of the domain
+	Size    int
+	Overlap int
+}
+
+// Move is the change in position of one URL between two rankings
+type Move struct {
+	URL          string
+	RankA, RankB int
+	ScoreA       float64
+	ScoreB       float64
+}
+
+// Shift is how many positions the URL moved, positive if it rose in b
+func (m Move) Shift() int {
+	return m.RankA - m.RankB
+}
+
+// Compare measures the distance and rank correlation between the rankings
+// a and b, using the top k URLs for precision and per domain overlap.
+// Positions are recomputed from the scores, so the rows may come in any
+// order.
+func Compare(a, b []Row, k int) *Comparison {
+	scoresA, scoresB := rowScores(a), rowScores(b)
+	orderA, orderB := byScore(scoresA), byScore(scoresB)
+	cmp := &Comparison{SizeA: len(scoresA), SizeB: len(scoresB), K: k}
+
+	// Distances over the union of both rankings
+	for url, sa := range scoresA {
+		sb, ok := scoresB[url]
+		if ok {
+			cmp.Common++
+		}
+		cmp.addDiff(sa - sb)
+	}
+	for url, sb := range scoresB {
+		if _, ok := scoresA[url]; !ok {
+			cmp.addDiff(sb)
+		}
+	}
+	cmp.L2 = math.Sqrt(cmp.L2)
+
+	// Scores of the common URLs in the order of a
+	var x, y []float64
+	positionB := make(map[string]int, len(orderB))
+	for idx, url := range orderB {
+		positionB[url] = idx + 1
+	}
+	for idx, url := range orderA {
+		pos, ok := positionB[url]
+		if !ok {
+			continue
+		}
+		x = append(x, scoresA[url])
+		y = append(y, scoresB[url])
+		cmp.Movers = append(cmp.Movers, Move{url, idx + 1, pos, scoresA[url], scoresB[url]})
+	}
+	cmp.Kendall = kendallTau(x, y)
+	cmp.Spearman = spearmanRho(x, y)
+	sort.SliceStable(cmp.Movers, func(i, j int) bool {
+		return abs(cmp.Movers[i].Shift()) > abs(cmp.Movers[j].Shift())
+	})
+
+	if k > 0 {
+		topA, topB := topOf(orderA, k), topOf(orderB, k)
+		size := len(topA)
+		if len(topB) < size {
+			size = len(topB)
+		}
+		cmp.Precision = math.NaN()
+		if size > 0 {
+			cmp.Precision = float64(overlap(topA, topB)) / float64(size)
+		}
+		cmp.Domains = domainOverlap(orderA, orderB, k)
+	}
+	return cmp
+}
+
+// Metric returns the named metric and whether a larger value means the
+// rankings are further apart
+func (cmp *Comparison) Metric(name string) (value float64, distance bool, err error) {
+	switch name {
+	case MetricL1:
+		return cmp.L1, true, nil
+	case MetricL2:
+		return cmp.L2, true, nil
+	case MetricLInf:
+		return cmp.LInf, true, nil
+	case MetricKendall:
+		return cmp.Kendall, false, nil
+	case MetricSpearman:
+		return cmp.Spearman, false, nil
+	case MetricPrecision:
+		return cmp.Precision, false, nil
+	}
+	return 0, false, fmt.Errorf("unknown metric %q, want one of %s, %s, %s, %s, %s or %s", name,
+		MetricL1, MetricL2, MetricLInf, MetricKendall, MetricSpearman, MetricPrecision)
+}
+
+// Exceeds reports whether the named metric is past threshold: above it
+// for distances, below it for correlations and precision. A metric that
+// is undefined for the rankings, such as tau when all the scores tie or
+// fewer than two URLs are common, cannot be checked and gives an error.
+func (cmp *Comparison) Exceeds(name string, threshold float64) (bool, error) {
+	value, distance, err := cmp.Metric(name)
+	if err != nil {
+		return false, err
+	}
+	if math.IsNaN(value) {
+		return false, fmt.Errorf("%s is undefined for these rankings: %s", name, cmp.undefined(name))
+	}
+	if distance {
+		return value > threshold, nil
+	}
+	return value < threshold, nil
+}
+
+// Explains why the named metric is NaN
+func (cmp *Comparison) undefined(name string) string {
+	if name == MetricPrecision {
+		return "a ranking is empty"
+	}
+	if cmp.Common < 2 {
+		return fmt.Sprintf("they share %d URLs, fewer than two", cmp.Common)
+	}
+	return "the scores of the common URLs all tie in one of them"
+}
+
+func (cmp *Comparison) addDiff(diff float64) {
+	diff = math.Abs(diff)
+	cmp.L1 += diff
+	cmp.L2 += diff * diff
+	if diff > cmp.LInf {
+		cmp.LInf = diff
+	}
+}
+
+func rowScores(rows []Row) map[string]float64 {
+	scores := make(map[string]float64, len(rows))
+	for _, row := range rows {
+		scores[row.URL] = row.Score
+	}
+	return scores
+}
+
+// Returns the URLs of scores best first, breaking ties by URL like Top
+func byScore(scores map[string]float64) []string {
+	urls := make([]string, 0, len(scores))
+	for url := range scores {
+		urls = append(urls, url)
+	}
+	sort.Slice(urls, func(i, j int) bool {
+		if scores[urls[i]] != scores[urls[j]] {
+			return scores[urls[i]] > scores[urls[j]]
+		}
+		return urls[i] < urls[j]
+	})
+	return urls
+}
+
+func topOf(urls []string, k int) []string {
+	if k < len(urls) {
+		return urls[:k]
+	}
+	return urls
+}
+
+func overlap(a, b []string) int {
+	in := make(map[string]bool, len(a))
+	for _, url := range a {
+		in[url] = true
+	}
+	count := 0
+	for _, url := range b {
+		if in[url] {
+			count++
+		}
+	}
+	return count
+}
+
+// Compares the top k URLs of every domain. Both orders are best first,
+// so grouping them by domain keeps each group sorted.
+func domainOverlap(orderA, orderB []string, k int) []DomainOverlap {
+	group := func(order []string) map[string][]string {
+		groups := make(map[string][]string)
+		for _, url := range order {
+			domain, _ := Domain(url)
+			if len(groups[domain]) < k {
+				groups[domain] = append(groups[domain], url)
+			}
+		}
+		return groups
+	}
+	groupsA, groupsB := group(orderA), group(orderB)
+	for domain := range groupsB {
+		if _, ok := groupsA[domain]; !ok {
+			groupsA[domain] = nil
+		}
+	}
+	domains := make([]DomainOverlap, 0, len(groupsA))
+	for domain, topA := range groupsA {
+		topB := groupsB[domain]
+		size := len(topA)
+		if len(topB) < size {
+			size = len(topB)
+		}
+		domains = append(domains, DomainOverlap{domain, size, overlap(topA, topB)})
+	}
+	sort.Slice(domains, func(i, j int) bool { return domains[i].Domain < domains[j].Domain })
+	return domains
+}
+
+// Kendall's tau-b of the paired samples x and y in O(n log n), counting
+// discordant pairs with a merge sort (Knight, 1966)
+func kendallTau(x, y []float64) float64 {
+	n := len(x)
+	if n < 2 {
+		return math.NaN()
+	}
+	idx := make([]int, n)
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.Slice(idx, func(i, j int) bool {
+		if x[idx[i]] != x[idx[j]] {
+			return x[idx[i]] < x[idx[j]]
+		}
+		return y[idx[i]] < y[idx[j]]
+	})
+	pairs := func(run int64) int64 { return run * (run - 1) / 2 }
+
+	// Pairs tied in x, and tied in both x and y
+	var tiedX, tiedXY int64
+	runX, runXY := int64(1), int64(1)
+	for i := 1; i <= n; i++ {
+		if i < n && x[idx[i]] == x[idx[i-1]] {
+			runX++
+			if y[idx[i]] == y[idx[i-1]] {
+				runXY++
+			} else {
+				tiedXY += pairs(runXY)
+				runXY = 1
+			}
+			continue
+		}
+		tiedX += pairs(runX)
+		tiedXY += pairs(runXY)
+		runX, runXY = 1, 1
+	}
+
+	// Sorting y by merge sort counts the swaps, the discordant pairs
+	ys := make([]float64, n)
+	for i, j := range idx {
+		ys[i] = y[j]
+	}
+	swaps := mergeCount(ys, make([]float64, n))
+
+	var tiedY int64
+	run := int64(1)
+	for i := 1; i <= n; i++ {
+		if i < n && ys[i] == ys[i-1] {
+			run++
+			continue
+		}
+		tiedY += pairs(run)
+		run = 1
+	}
+
+	total := pairs(int64(n))
+	concordantMinusDiscordant := total - tiedX - tiedY + tiedXY - 2*swaps
+	denominator := math.Sqrt(float64(total-tiedX) * float64(total-tiedY))
+	if denominator == 0 {
+		return math.NaN()
+	}
+	return float64(concordantMinusDiscordant) / denominator
+}
+
+// Sorts v in place using buf and returns the number of inversions
+func mergeCount(v, buf []float64) int64 {
+	if len(v) < 2 {
+		return 0
+	}
+	mid := len(v) / 2
+	swaps := mergeCount(v[:mid], buf[:mid]) + mergeCount(v[mid:], buf[mid:])
+	i, j, k := 0, mid, 0
+	for i < mid && j < len(v) {
+		if v[j] < v[i] {
+			buf[k] = v[j]
+			swaps += int64(mid - i)
+			j++
+		} else {
+			buf[k] = v[i]
+			i++
+		}
+		k++
+	}
+	k += copy(buf[k:], v[i:mid])
+	copy(buf[k:], v[j:])
+	copy(v, buf[:len(v)])
+	return swaps
+}
+
+// Spearman's rho of the paired samples x and y: the correlation of their
+// ranks, where tied values share the average rank
+func spearmanRho(x, y []float64) float64 {
+	if len(x) < 2 {
+		return math.NaN()
+	}
+	rx, ry := fractionalRanks(x), fractionalRanks(y)
+	mean := float64(len(x)+1) / 2
+	var cov, varX, varY float64
+	for i := range rx {
+		dx, dy := rx[i]-mean, ry[i]-mean
+		cov += dx * dy
+		varX += dx * dx
+		varY += dy * dy
+	}
+	if varX == 0 || varY == 0 {
+		return math.NaN()
+	}
+	return cov / math.Sqrt(varX*varY)
+}
+
+func fractionalRanks(v []float64) []float64 {
+	idx := make([]int, len(v))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.Slice(idx, func(i, j int) bool { return v[idx[i]] < v[idx[j]] })
+	ranks := make([]float64, len(v))
+	for start := 0; start < len(idx); {
+		end := start + 1
+		for end < len(idx) && v[idx[end]] == v[idx[start]] {
+			end++
+		}
+		// Positions start..end-1 share the average of ranks start+1..end
+		avg := float64(start+end+1) / 2
+		for _, i := range idx[start:end] {
+			ranks[i] = avg
+		}
+		start = end
+	}
+	return ranks
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,61 @@
+package pagerank
+
+import (
+	"math"
+	"testing"
+)
+
+// Returns rows scoring urls from best to worst
+func rowsOf(urls ...string) []Row {
+	rows := make([]Row, len(urls))
+	for idx, url := range urls {
+		rows[idx] = Row{URL: url, Score: float64(len(urls) - idx)}
+	}
+	return rows
+}
+
+func TestComparePrecision(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		a, b      []Row
+		k         int
+		precision float64
+	}{
+		{"identical shorter than k", rowsOf("a", "b", "c"), rowsOf("a", "b", "c"), 20, 1},
+		{"identical longer than k", rowsOf("a", "b", "c", "d"), rowsOf("a", "b", "c", "d"), 2, 1},
+		{"disjoint", rowsOf("a", "b", "c"), rowsOf("x", "y", "z"), 20, 0},
+		{"one shorter than k", rowsOf("a", "b", "c", "d"), rowsOf("a", "x"), 3, 0.5},
+		{"top k differs", rowsOf("a", "b", "c", "d"), rowsOf("a", "c", "b", "d"), 2, 0.5},
+		{"empty", nil, rowsOf("a"), 5, math.NaN()},
+	} {
+		cmp := Compare(tc.a, tc.b, tc.k)
+		if got := cmp.Precision; got != tc.precision && !(math.IsNaN(got) && math.IsNaN(tc.precision)) {
+			t.Errorf("%s: precision %g, want %g", tc.name, got, tc.precision)
+		}
+	}
+}
+
+func TestCompareExceeds(t *testing.T) {
+	identical := Compare(rowsOf("a", "b", "c"), rowsOf("a", "b", "c"), 20)
+	for _, metric := range []string{MetricPrecision, MetricKendall, MetricSpearman} {
+		if exceeds, err := identical.Exceeds(metric, 0.9); err != nil || exceeds {
+			t.Errorf("identical rankings: %s exceeds 0.9 = %v, %v", metric, exceeds, err)
+		}
+	}
+	if exceeds, err := identical.Exceeds(MetricL1, 0); err != nil || exceeds {
+		t.Errorf("identical rankings: l1 exceeds 0 = %v, %v", exceeds, err)
+	}
+
+	disjoint := Compare(rowsOf("a", "b"), rowsOf("x", "y"), 20)
+	if exceeds, err := disjoint.Exceeds(MetricPrecision, 0.9); err != nil || !exceeds {
+		t.Errorf("disjoint rankings: precision exceeds 0.9 = %v, %v", exceeds, err)
+	}
+	if _, err := disjoint.Exceeds(MetricKendall, 0.9); err == nil {
+		t.Error("disjoint rankings: tau without common URLs gave no error")
+	}
+
+	tied := []Row{{URL: "a", Score: 1}, {URL: "b", Score: 1}, {URL: "c", Score: 1}}
+	if _, err := Compare(tied, rowsOf("a", "b", "c"), 20).Exceeds(MetricSpearman, 0.9); err == nil {
+		t.Error("all tied scores: rho gave no error")
+	}
+}
@@ -0,0 +1,125 @@
+package pagerank
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Norm selects how the residual between two iterations is measured
+type Norm int
+
+const (
+	// NormL1 is the sum of the absolute differences
+	NormL1 Norm = iota
+	// NormL2 is the square root of the sum of the squared differences
+	NormL2
+	// NormLInf is the largest absolute difference
+	NormLInf
+)
+
+var normNames = []string{"l1", "l2", "linf"}
+
+func (n Norm) String() string {
+	if n < 0 || int(n) >= len(normNames) {
+		return fmt.Sprintf("Norm(%d)", int(n))
+	}
+	return normNames[n]
+}
+
+// ParseNorm returns the norm called name: l1, l2 or linf
+func ParseNorm(name string) (Norm, error) {
+	for n, normName := range normNames {
+		if name == normName {
+			return Norm(n), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown norm %q, want one of %s", name, strings.Join(normNames, ", "))
+}
+
+// Adds the absolute difference diff to the partial residual acc
+func (n Norm) add(acc *accumulator, diff float64) {
+	switch n {
+	case NormL2:
+		acc.add(diff * diff)
+	case NormLInf:
+		acc.sum = math.Max(acc.sum, diff)
+	default:
+		acc.add(diff)
+	}
+}
+
+// Merges the partial residual of another part of the vector into acc
+func (n Norm) merge(acc *accumulator, partial float64) {
+	if n == NormLInf {
+		acc.sum = math.Max(acc.sum, partial)
+		return
+	}
+	acc.add(partial)
+}
+
+// Turns a partial residual into the residual
+func (n Norm) finish(partial float64) float64 {
+	if n == NormL2 {
+		return math.Sqrt(partial)
+	}
+	return partial
+}
+
+// Combine merges the partial residuals of disjoint parts of a vector, as
+// returned by Partition.Finish, into the residual of the whole vector
+func (n Norm) Combine(partials []float64) float64 {
+	var acc accumulator
+	for _, partial := range partials {
+		n.merge(&acc, partial)
+	}
+	return n.finish(acc.value())
+}
+
+// Returns the partial residual between x and y in norm n
+func partialResidual[F real](n Norm, x, y []F) float64 {
+	var acc accumulator
+	for i := range x {
+		n.add(&acc, math.Abs(float64(x[i])-float64(y[i])))
+	}
+	return acc.value()
+}
+
+// Residual returns the distance between x and y in norm n
+func (n Norm) Residual(x, y []float64) float64 {
+	return n.finish(partialResidual(n, x, y))
+}
+
+// ConvergenceError is returned when the iteration cap is reached before
+// the residual falls below the tolerance. The result returned with it
+// holds the values of the last iteration.
+type ConvergenceError struct {
+	Iterations int
+	// Residual of the last iteration and the tolerance it missed
+	Residual float64
+	Epsilon  float64
+	Norm     Norm
+}
+
+func (e *ConvergenceError) Error() string {
+	return fmt.Sprintf("no convergence after %d iterations: %s residual %g is above the tolerance %g",
+		e.Iterations, e.Norm, e.Residual, e.Epsilon)
+}
+
+// Reports whether the residual of an iteration is below the tolerance
+func (opts Options) converged(residual float64) bool {
+	return residual < opts.Epsilon
+}
+
+// Returns a ConvergenceError if the last residual of history is not
+// below the tolerance, and nil otherwise
+func (opts Options) convergenceErr(history []float64) error {
+	if len(history) == 0 {
+		return nil
+	}
+	residual := history[len(history)-1]
+	if opts.converged(residual) {
+		return nil
+	}
+	return &ConvergenceError{len(history), residual, opts.Epsilon, opts.Norm}
+}
@@ -0,0 +1,234 @@
+package pagerank
+
+import "../canonical"
+
+// CSR is a compressed sparse row form of a link graph. Every URL is
+// interned to a dense uint32 ID, so the page rank iteration only touches
+// flat integer arrays instead of hashing URL strings.
+//
+// The in-links of node i are InLinks[InOffsets[i]:InOffsets[i+1]].
+type CSR struct {
+	// Maps a node ID to its URL
+	URLs []string
+	// Maps a URL to its node ID
+	IDs map[string]uint32
+	// Start of each node's in-links in InLinks, with one extra entry
+	// holding the total number of edges
+	InOffsets []uint32
+	// Source node IDs of every edge, grouped by destination
+	InLinks []uint32
+	// Number of outlinks of each node
+	OutDegree []uint32
+	// Weight attribute of every edge in InLinks, nil when no edge had
+	// one. The engines only rank by weight after Collapse with
+	// LinksWeighted, which also sets OutWeights.
+	Weights []float64
+	// Total weight of the outlinks of each node, nil unless weighted
+	OutWeights []float64
+}
+
+// NumNodes returns the number of nodes in the graph
+func (c *CSR) NumNodes() int {
+	return len(c.URLs)
+}
+
+// NumEdges returns the number of edges in the graph
+func (c *CSR) NumEdges() int {
+	return len(c.InLinks)
+}
+
+// ID returns the node ID of url and whether url is part of the graph
+func (c *CSR) ID(url string) (uint32, bool) {
+	id, ok := c.IDs[url]
+	return id, ok
+}
+
+// In returns the IDs of the nodes that link to node id
+func (c *CSR) In(id uint32) []uint32 {
+	return c.InLinks[c.InOffsets[id]:c.InOffsets[id+1]]
+}
+
+// InWeights returns the weights of the in-links of node id, in the order
+// of In, or nil when the graph is not weighted
+func (c *CSR) InWeights(id uint32) []float64 {
+	if c.OutWeights == nil {
+		return nil
+	}
+	return c.Weights[c.InOffsets[id]:c.InOffsets[id+1]]
+}
+
+// Returns the part of node j's value v that flows over its link to a
+// node whose in-link weights are w, with the link being the k-th of them.
+// That is v in proportion to the weight of the link when w is not nil,
+// and v over the outlinks of j otherwise.
+func (c *CSR) flow(v float64, j uint32, w []float64, k int) float64 {
+	if w != nil {
+		return v * w[k] / c.OutWeights[j]
+	}
+	return v / float64(c.OutDegree[j])
+}
+
+// csrBuilder interns URLs and collects edges as ID pairs, then sorts
+// them by destination in a single counting pass.
+type csrBuilder struct {
+	urls []string
+	ids  map[string]uint32
+	src  []uint32
+	dest []uint32
+	// Weight of every edge, nil until an edge has a weight attribute
+	weights []float64
+}
+
+func newCSRBuilder() *csrBuilder {
+	return &csrBuilder{ids: make(map[string]uint32)}
+}
+
+// Returns the ID of url, assigning the next free one on first sight
+func (b *csrBuilder) intern(url string) uint32 {
+	id, ok := b.ids[url]
+	if !ok {
+		id = uint32(len(b.urls))
+		b.ids[url] = id
+		b.urls = append(b.urls, url)
+	}
+	return id
+}
+
+func (b *csrBuilder) addEdge(src, dest string) {
+	s := b.intern(src)
+	d := b.intern(dest)
+	b.src = append(b.src, s)
+	b.dest = append(b.dest, d)
+	if b.weights != nil {
+		b.weights = append(b.weights, 1)
+	}
+}
+
+// Like addEdge, but keeps the weight attribute of the edge
+func (b *csrBuilder) addEdgeAttrs(src, dest string, attrs Attrs) {
+	if w, ok := edgeWeight(attrs); ok {
+		b.addWeightedEdge(src, dest, w)
+	} else {
+		b.addEdge(src, dest)
+	}
+}
+
+func (b *csrBuilder) addWeightedEdge(src, dest string, w float64) {
+	b.addEdge(src, dest)
+	if b.weights == nil {
+		// Every edge before the first weighted one weighs one
+		b.weights = make([]float64, len(b.src))
+		for i := range b.weights {
+			b.weights[i] = 1
+		}
+	}
+	b.weights[len(b.weights)-1] = w
+}
+
+func (b *csrBuilder) build() *CSR {
+	n := len(b.urls)
+	c := &CSR{
+		URLs:      b.urls,
+		IDs:       b.ids,
+		InOffsets: make([]uint32, n+1),
+		InLinks:   make([]uint32, len(b.src)),
+		OutDegree: make([]uint32, n),
+	}
+	// Count the in-links of every node, then turn the counts into offsets
+	for i, d := range b.dest {
+		c.InOffsets[d+1]++
+		c.OutDegree[b.src[i]]++
+	}
+	for i := 0; i < n; i++ {
+		c.InOffsets[i+1] += c.InOffsets[i]
+	}
+	// Place each edge, keeping the order in which edges were added
+	next := make([]uint32, n)
+	copy(next, c.InOffsets[:n])
+	if b.weights != nil {
+		c.Weights = make([]float64, len(b.weights))
+	}
+	for i, d := range b.dest {
+		c.InLinks[next[d]] = b.src[i]
+		if b.weights != nil {
+			c.Weights[next[d]] = b.weights[i]
+		}
+		next[d]++
+	}
+	return c
+}
+
+// NewCSR converts g into compressed sparse row form. Node IDs follow the
+// order of g.Nodes.
+func NewCSR(g *Graph) *CSR {
+	b := newCSRBuilder()
+	for _, url := range g.Nodes {
+		b.intern(url)
+	}
+	for _, url := range g.Nodes {
+		for _, inNode := range g.AdjacencyList[url] {
+			b.addEdgeAttrs(inNode, url, g.EdgeAttrs[Edge{inNode, url}])
+		}
+	}
+	c := b.build()
+	// Subgraphs may hold outlinks that point outside of the graph
+	for url, n := range g.OutLinks {
+		if id, ok := c.IDs[url]; ok {
+			c.OutDegree[id] = uint32(n)
+		}
+	}
+	return c
+}
+
+// ReadDotFileCSR reads the dot file at path straight into compressed
+// sparse row form, without building the map based Graph first. Node
+// attributes are not kept, and of the edge attributes only the weight.
+// The URLs are brought into the canonical.Default form.
+func ReadDotFileCSR(path string) (*CSR, error) {
+	return ReadCSR(path, FormatDot, canonical.Default)
+}
+
+// ReadCSR is like ReadDotFileCSR for any input format and
+// canonicalization rules, see ScanFile
+func ReadCSR(path, format string, rules canonical.Rules) (*CSR, error) {
+	b := newCSRBuilder()
+	err := ScanFile(path, format, rules, Handler{
+		Node: func(url string, attrs Attrs) { b.intern(url) },
+		Edge: func(src, dest string, attrs Attrs) { b.addEdgeAttrs(src, dest, attrs) },
+	})
+	if err != nil {
+		return nil, err
+	}
+	return b.build(), nil
+}
+
+// CSRResult holds the outcome of ComputeCSR
+type CSRResult struct {
+	// Final page rank values, indexed by node ID
+	Ranks []float64
+	// Number of iterations until the values converged
+	Iterations int
+	// Residual between consecutive iterations in Options.Norm, one per
+	// iteration
+	History []float64
+}
+
+// Ranks converts a rank vector indexed by node ID into a map keyed by URL
+func (c *CSR) Ranks(v []float64) Ranks {
+	ranks := make(Ranks, len(v))
+	for id, value := range v {
+		ranks[c.URLs[id]] = value
+	}
+	return ranks
+}
+
+// Vector converts a map keyed by URL into a vector indexed by node ID.
+// URLs that are not part of the graph are ignored and nodes without a
+// value are zero.
+func (c *CSR) Vector(r Ranks) []float64 {
+	v := make([]float64, c.NumNodes())
+	for id, url := range c.URLs {
+		v[id] = float64(r[url])
+	}
+	return v
+}
@@ -0,0 +1,59 @@
+package pagerank
+
+import (
+	"net/url"
+	"strings"
+
+	"../canonical"
+	"../registrable"
+)
+
+// IsDomain reports whether url is part of domain, given either as
+// returned by Domain or by its first label, as in "ceng" for
+// ceng.calpoly.edu. URLs without a host are part of "".
+func IsDomain(url, domain string) bool {
+	d, _ := Domain(url)
+	return d == domain || (domain != "" && d == domain+"."+registrable.Domain(d))
+}
+
+// Domain returns the site of url that the partitions and the per domain
+// reports group URLs by: the label right before the registrable domain
+// of its host together with the registrable domain. For example
+// http://www.ceng.calpoly.edu/ gives "ceng.calpoly.edu", and a URL on
+// the registrable domain itself, such as http://calpoly.edu/, gives
+// "calpoly.edu". The second result is false when the URL has no host.
+func Domain(rawURL string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return "", false
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	domain := registrable.Domain(host)
+	sub := strings.TrimSuffix(strings.TrimSuffix(host, domain), ".")
+	if sub == "" || sub == host {
+		return domain, true
+	}
+	return sub[strings.LastIndex(sub, ".")+1:] + "." + domain, true
+}
+
+// GetDomains loops through the source URL of each link in the graph at
+// path and returns every domain found, with "" standing for the URLs
+// without a host
+func GetDomains(path, format string, rules canonical.Rules) ([]string, error) {
+	// Map to keep track if we have seen a domain before
+	visitedDomain := make(map[string]bool)
+	err := ScanFile(path, format, rules, Handler{
+		Edge: func(src, dest string, attrs Attrs) {
+			domain, _ := Domain(src)
+			visitedDomain[domain] = true
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	domains := make([]string, 0, len(visitedDomain))
+	for domain := range visitedDomain {
+		domains = append(domains, domain)
+	}
+	return domains, nil
+}
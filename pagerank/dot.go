@@ -0,0 +1,698 @@
+package pagerank
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// Attrs holds the attributes of a node or edge, such as [weight=2]
+type Attrs map[string]string
+
+// SyntaxError reports a malformed dot file
+type SyntaxError struct {
+	// Path of the file, empty when reading from a plain reader
+	Path string
+	// Line the error was found on, starting at one
+	Line int
+	Msg  string
+}
+
+func (e *SyntaxError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+	}
+	return fmt.Sprintf("%s:%d: %s", e.Path, e.Line, e.Msg)
+}
+
+// Handler receives the nodes and edges of a graph as it is read.
+// Either function may be nil.
+type Handler struct {
+	// Called for every node that is declared on its own, such as a dot
+	// node statement. For dot files attrs holds the node defaults in
+	// scope merged with the node's own attributes.
+	Node func(id string, attrs Attrs)
+	// Called for every edge, so a -> b -> c gives two calls. For dot
+	// files attrs holds the edge defaults in scope merged with the
+	// edge's own attributes.
+	Edge func(src, dest string, attrs Attrs)
+}
+
+// Kinds of dot tokens
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokID
+	tokEdgeOp
+	tokLBrace
+	tokRBrace
+	tokLBracket
+	tokRBracket
+	tokSemi
+	tokComma
+	tokEqual
+	tokColon
+)
+
+var tokenNames = map[tokenKind]string{
+	tokEOF:      "end of file",
+	tokID:       "ID",
+	tokEdgeOp:   "edge operator",
+	tokLBrace:   "'{'",
+	tokRBrace:   "'}'",
+	tokLBracket: "'['",
+	tokRBracket: "']'",
+	tokSemi:     "';'",
+	tokComma:    "','",
+	tokEqual:    "'='",
+	tokColon:    "':'",
+}
+
+type token struct {
+	kind tokenKind
+	text string
+	// Quoted IDs are never keywords
+	quoted bool
+	line   int
+}
+
+func (t token) String() string {
+	if t.kind == tokID || t.kind == tokEdgeOp {
+		return fmt.Sprintf("%q", t.text)
+	}
+	return tokenNames[t.kind]
+}
+
+// dotLexer splits a dot file into tokens. Unquoted IDs are read more
+// leniently than the dot language requires, because the crawler writes
+// URLs without quotes: a bare ID runs until whitespace or one of {}[];,"
+// and may contain ':', '/', '=' and the like. '=' and ':' only end a
+// bare ID that is a plain identifier, as in rankdir=LR or node:port.
+type dotLexer struct {
+	r *bufio.Reader
+	// Characters pushed back by unread, read again before r
+	pending []rune
+	line    int
+	// Edge operator of the graph, "->" or "--"
+	edgeOp string
+	// Inside an attribute list '=' and ',' always end a bare ID
+	inAttrs bool
+	// Set when the previous character read was a newline
+	lineStart bool
+}
+
+func newDotLexer(r io.Reader) *dotLexer {
+	return &dotLexer{r: bufio.NewReader(r), line: 1, edgeOp: "->", lineStart: true}
+}
+
+func (l *dotLexer) read() (rune, bool) {
+	var c rune
+	if n := len(l.pending); n > 0 {
+		c = l.pending[n-1]
+		l.pending = l.pending[:n-1]
+	} else {
+		var err error
+		if c, _, err = l.r.ReadRune(); err != nil {
+			return 0, false
+		}
+	}
+	if c == '\n' {
+		l.line++
+	}
+	return c, true
+}
+
+func (l *dotLexer) unread(c rune) {
+	l.pending = append(l.pending, c)
+	if c == '\n' {
+		l.line--
+	}
+}
+
+func (l *dotLexer) peek() (rune, bool) {
+	c, ok := l.read()
+	if ok {
+		l.unread(c)
+	}
+	return c, ok
+}
+
+func (l *dotLexer) errorf(format string, args ...interface{}) error {
+	return &SyntaxError{Line: l.line, Msg: fmt.Sprintf(format, args...)}
+}
+
+// Skips white space and comments, including lines starting with '#'
+func (l *dotLexer) skip() error {
+	for {
+		c, ok := l.read()
+		if !ok {
+			return nil
+		}
+		switch {
+		case c == '\n':
+			l.lineStart = true
+			continue
+		case unicode.IsSpace(c):
+			continue
+		case c == '#' && l.lineStart:
+			for c != '\n' {
+				if c, ok = l.read(); !ok {
+					return nil
+				}
+			}
+			l.lineStart = true
+			continue
+		case c == '/':
+			next, _ := l.peek()
+			if next == '/' {
+				for c != '\n' {
+					if c, ok = l.read(); !ok {
+						return nil
+					}
+				}
+				l.lineStart = true
+				continue
+			}
+			if next == '*' {
+				line := l.line
+				l.read()
+				prev := rune(0)
+				for {
+					if c, ok = l.read(); !ok {
+						return &SyntaxError{Line: line, Msg: "unterminated comment"}
+					}
+					if prev == '*' && c == '/' {
+						break
+					}
+					prev = c
+				}
+				continue
+			}
+		}
+		l.unread(c)
+		l.lineStart = false
+		return nil
+	}
+}
+
+// Reports whether s is a plain dot identifier or numeral
+func isPlainID(s string) bool {
+	for _, c := range s {
+		if !(c == '_' || c == '.' || c == '-' || unicode.IsLetter(c) || unicode.IsDigit(c)) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func (l *dotLexer) next() (token, error) {
+	if err := l.skip(); err != nil {
+		return token{}, err
+	}
+	line := l.line
+	c, ok := l.read()
+	if !ok {
+		return token{kind: tokEOF, line: line}, nil
+	}
+	switch c {
+	case '{':
+		return token{kind: tokLBrace, line: line}, nil
+	case '}':
+		return token{kind: tokRBrace, line: line}, nil
+	case '[':
+		l.inAttrs = true
+		return token{kind: tokLBracket, line: line}, nil
+	case ']':
+		l.inAttrs = false
+		return token{kind: tokRBracket, line: line}, nil
+	case ';':
+		return token{kind: tokSemi, line: line}, nil
+	case ',':
+		return token{kind: tokComma, line: line}, nil
+	case '=':
+		return token{kind: tokEqual, line: line}, nil
+	case ':':
+		return token{kind: tokColon, line: line}, nil
+	case '"':
+		return l.quoted(line)
+	case '<':
+		return l.html(line)
+	}
+	if c == '-' {
+		if next, _ := l.peek(); next == '>' || next == '-' {
+			l.read()
+			return token{kind: tokEdgeOp, text: string([]rune{c, next}), line: line}, nil
+		}
+	}
+	l.unread(c)
+	return l.bare(line)
+}
+
+// Reads a double quoted ID, joining "a" + "b" into one
+func (l *dotLexer) quoted(line int) (token, error) {
+	var b strings.Builder
+	for {
+		c, ok := l.read()
+		if !ok {
+			return token{}, &SyntaxError{Line: line, Msg: "unterminated string"}
+		}
+		if c == '"' {
+			break
+		}
+		if c == '\\' {
+			next, ok := l.read()
+			if !ok {
+				return token{}, &SyntaxError{Line: line, Msg: "unterminated string"}
+			}
+			switch next {
+			case '"':
+				b.WriteRune('"')
+			case '\n':
+				// Line continuation
+			default:
+				b.WriteRune(c)
+				b.WriteRune(next)
+			}
+			continue
+		}
+		b.WriteRune(c)
+	}
+	// Concatenation with +
+	if err := l.skip(); err != nil {
+		return token{}, err
+	}
+	if c, _ := l.peek(); c == '+' {
+		l.read()
+		if err := l.skip(); err != nil {
+			return token{}, err
+		}
+		if c, _ := l.read(); c != '"' {
+			return token{}, l.errorf("expected string after '+'")
+		}
+		rest, err := l.quoted(l.line)
+		if err != nil {
+			return token{}, err
+		}
+		b.WriteString(rest.text)
+	}
+	return token{kind: tokID, text: b.String(), quoted: true, line: line}, nil
+}
+
+// Reads an HTML ID such as <<b>text</b>>
+func (l *dotLexer) html(line int) (token, error) {
+	var b strings.Builder
+	depth := 1
+	for {
+		c, ok := l.read()
+		if !ok {
+			return token{}, &SyntaxError{Line: line, Msg: "unterminated HTML string"}
+		}
+		if c == '<' {
+			depth++
+		} else if c == '>' {
+			depth--
+			if depth == 0 {
+				break
+			}
+		}
+		b.WriteRune(c)
+	}
+	return token{kind: tokID, text: b.String(), quoted: true, line: line}, nil
+}
+
+// Reports whether the rest of a bare word, after a ':', is a port such
+// as p1 or p1:n. The characters are read ahead and pushed back.
+func (l *dotLexer) portFollows() bool {
+	var rest []rune
+	for {
+		c, ok := l.read()
+		if !ok {
+			break
+		}
+		rest = append(rest, c)
+		if unicode.IsSpace(c) || strings.ContainsRune("{}[];,\"=", c) {
+			break
+		}
+	}
+	for i := len(rest) - 1; i >= 0; i-- {
+		l.unread(rest[i])
+	}
+	word := string(rest)
+	if idx := strings.IndexFunc(word, func(c rune) bool {
+		return unicode.IsSpace(c) || strings.ContainsRune("{}[];,\"=", c)
+	}); idx >= 0 {
+		word = word[:idx]
+	}
+	if idx := strings.Index(word, l.edgeOp); idx >= 0 {
+		word = word[:idx]
+	}
+	for _, part := range strings.SplitN(word, ":", 2) {
+		if !isPlainID(part) {
+			return false
+		}
+	}
+	return true
+}
+
+// Reads an unquoted ID
+func (l *dotLexer) bare(line int) (token, error) {
+	var b strings.Builder
+	for {
+		c, ok := l.read()
+		if !ok {
+			break
+		}
+		if unicode.IsSpace(c) || strings.ContainsRune("{}[];,\"", c) {
+			l.unread(c)
+			break
+		}
+		if c == '=' && (l.inAttrs || isPlainID(b.String())) {
+			l.unread(c)
+			break
+		}
+		// Keep "http://" and "mailto:a@b" inside the ID
+		if c == ':' && isPlainID(b.String()) && l.portFollows() {
+			l.unread(c)
+			break
+		}
+		if c == '-' {
+			if next, _ := l.peek(); string([]rune{c, next}) == l.edgeOp {
+				l.unread(c)
+				break
+			}
+		}
+		b.WriteRune(c)
+	}
+	if b.Len() == 0 {
+		c, _ := l.read()
+		return token{}, l.errorf("unexpected character %q", c)
+	}
+	return token{kind: tokID, text: b.String(), line: line}, nil
+}
+
+// dotParser turns the tokens of a dot file into calls to a Handler
+type dotParser struct {
+	lex     *dotLexer
+	tok     token
+	handler Handler
+}
+
+// Attribute defaults set by node and edge statements in a scope
+type dotScope struct {
+	node Attrs
+	edge Attrs
+}
+
+// ParseDot parses a dot file from r and calls h for every node and edge
+// statement. Edge chains such as a -> b -> c, edges to and from subgraphs
+// such as a -> {b c}, several statements per line, comments and quoted
+// IDs are all supported. Malformed input returns a *SyntaxError.
+func ParseDot(r io.Reader, h Handler) error {
+	p := &dotParser{lex: newDotLexer(r), handler: h}
+	if err := p.advance(); err != nil {
+		return err
+	}
+	return p.graph()
+}
+
+func (p *dotParser) advance() error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *dotParser) errorf(format string, args ...interface{}) error {
+	return &SyntaxError{Line: p.tok.line, Msg: fmt.Sprintf(format, args...)}
+}
+
+// Reports whether the current token is the keyword kw
+func (p *dotParser) keyword(kw string) bool {
+	return p.tok.kind == tokID && !p.tok.quoted && strings.EqualFold(p.tok.text, kw)
+}
+
+func (p *dotParser) expect(kind tokenKind) error {
+	if p.tok.kind != kind {
+		return p.errorf("expected %s, found %s", tokenNames[kind], p.tok)
+	}
+	return p.advance()
+}
+
+// graph : [strict] (graph | digraph) [ID] '{' stmt_list '}'
+func (p *dotParser) graph() error {
+	if p.keyword("strict") {
+		if err := p.advance(); err != nil {
+			return err
+		}
+	}
+	switch {
+	case p.keyword("digraph"):
+		p.lex.edgeOp = "->"
+	case p.keyword("graph"):
+		p.lex.edgeOp = "--"
+	default:
+		return p.errorf("expected graph or digraph, found %s", p.tok)
+	}
+	if err := p.advance(); err != nil {
+		return err
+	}
+	if p.tok.kind == tokID {
+		if err := p.advance(); err != nil {
+			return err
+		}
+	}
+	if err := p.expect(tokLBrace); err != nil {
+		return err
+	}
+	if _, err := p.stmtList(dotScope{node: Attrs{}, edge: Attrs{}}); err != nil {
+		return err
+	}
+	if err := p.expect(tokRBrace); err != nil {
+		return err
+	}
+	if p.tok.kind != tokEOF {
+		return p.errorf("unexpected %s after end of graph", p.tok)
+	}
+	return nil
+}
+
+// stmt_list : [stmt [';'] stmt_list]
+// Returns the IDs of every node in the list, for edges to subgraphs.
+func (p *dotParser) stmtList(scope dotScope) ([]string, error) {
+	nodes := []string{}
+	for p.tok.kind != tokRBrace && p.tok.kind != tokEOF {
+		ids, err := p.stmt(&scope)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, ids...)
+		if p.tok.kind == tokSemi {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return nodes, nil
+}
+
+// Merges the attributes of b over a copy of a, nil when both are empty
+func mergeAttrs(a, b Attrs) Attrs {
+	if len(a) == 0 && len(b) == 0 {
+		return nil
+	}
+	merged := make(Attrs, len(a)+len(b))
+	for k, v := range a {
+		merged[k] = v
+	}
+	for k, v := range b {
+		merged[k] = v
+	}
+	return merged
+}
+
+// stmt : node_stmt | edge_stmt | attr_stmt | ID '=' ID | subgraph
+func (p *dotParser) stmt(scope *dotScope) ([]string, error) {
+	// attr_stmt : (graph | node | edge) attr_list
+	for _, kw := range []string{"graph", "node", "edge"} {
+		if !p.keyword(kw) {
+			continue
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		attrs, err := p.attrList()
+		if err != nil {
+			return nil, err
+		}
+		switch kw {
+		case "node":
+			scope.node = mergeAttrs(scope.node, attrs)
+		case "edge":
+			scope.edge = mergeAttrs(scope.edge, attrs)
+		}
+		return nil, nil
+	}
+
+	isSubgraph := p.keyword("subgraph") || p.tok.kind == tokLBrace
+	ids, err := p.endpoint(*scope)
+	if err != nil {
+		return nil, err
+	}
+	if isSubgraph && p.tok.kind != tokEdgeOp {
+		return ids, nil
+	}
+	// ID '=' ID sets a graph attribute
+	if p.tok.kind == tokEqual {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokID {
+			return nil, p.errorf("expected ID after '=', found %s", p.tok)
+		}
+		return nil, p.advance()
+	}
+	if p.tok.kind != tokEdgeOp {
+		// node_stmt : node_id [attr_list]
+		attrs, err := p.attrList()
+		if err != nil {
+			return nil, err
+		}
+		if p.handler.Node != nil {
+			for _, id := range ids {
+				p.handler.Node(id, mergeAttrs(scope.node, attrs))
+			}
+		}
+		return ids, nil
+	}
+
+	// edge_stmt : (node_id | subgraph) edgeRHS [attr_list]
+	chain := [][]string{ids}
+	all := append([]string{}, ids...)
+	for p.tok.kind == tokEdgeOp {
+		if p.tok.text != p.lex.edgeOp {
+			return nil, p.errorf("edge operator %s in a graph that uses %q", p.tok, p.lex.edgeOp)
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		ids, err := p.endpoint(*scope)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, ids)
+		all = append(all, ids...)
+	}
+	attrs, err := p.attrList()
+	if err != nil {
+		return nil, err
+	}
+	if p.handler.Edge != nil {
+		attrs = mergeAttrs(scope.edge, attrs)
+		for i := 1; i < len(chain); i++ {
+			for _, src := range chain[i-1] {
+				for _, dest := range chain[i] {
+					p.handler.Edge(src, dest, attrs)
+				}
+			}
+		}
+	}
+	return all, nil
+}
+
+// Parses a node ID or a subgraph and returns the node IDs it stands for
+func (p *dotParser) endpoint(scope dotScope) ([]string, error) {
+	if p.keyword("subgraph") || p.tok.kind == tokLBrace {
+		return p.subgraph(scope)
+	}
+	if p.tok.kind != tokID {
+		return nil, p.errorf("expected node ID, found %s", p.tok)
+	}
+	id := p.tok.text
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	// port : ':' ID [':' compass_pt], which is not part of the node ID
+	for i := 0; i < 2 && p.tok.kind == tokColon; i++ {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokID {
+			return nil, p.errorf("expected port after ':', found %s", p.tok)
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	return []string{id}, nil
+}
+
+// subgraph : [subgraph [ID]] '{' stmt_list '}'
+func (p *dotParser) subgraph(scope dotScope) ([]string, error) {
+	if p.keyword("subgraph") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if p.tok.kind == tokID {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := p.expect(tokLBrace); err != nil {
+		return nil, err
+	}
+	// Defaults set inside the subgraph do not leak out of it
+	ids, err := p.stmtList(dotScope{node: mergeAttrs(nil, scope.node), edge: mergeAttrs(nil, scope.edge)})
+	if err != nil {
+		return nil, err
+	}
+	return ids, p.expect(tokRBrace)
+}
+
+// attr_list : '[' [a_list] ']' [attr_list]
+// a_list    : ID '=' ID [(';' | ',')] [a_list]
+func (p *dotParser) attrList() (Attrs, error) {
+	attrs := Attrs{}
+	for p.tok.kind == tokLBracket {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		for p.tok.kind != tokRBracket {
+			if p.tok.kind != tokID {
+				return nil, p.errorf("expected attribute name, found %s", p.tok)
+			}
+			key := p.tok.text
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			value := "true"
+			if p.tok.kind == tokEqual {
+				if err := p.advance(); err != nil {
+					return nil, err
+				}
+				if p.tok.kind != tokID {
+					return nil, p.errorf("expected value of attribute %q, found %s", key, p.tok)
+				}
+				value = p.tok.text
+				if err := p.advance(); err != nil {
+					return nil, err
+				}
+			}
+			attrs[key] = value
+			if p.tok.kind == tokComma || p.tok.kind == tokSemi {
+				if err := p.advance(); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if err := p.expect(tokRBracket); err != nil {
+			return nil, err
+		}
+	}
+	return attrs, nil
+}
@@ -0,0 +1,180 @@
+package pagerank
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// Formats attrs as "{k=v k=v}" with the keys sorted, "" when empty
+func formatAttrs(attrs Attrs) string {
+	if len(attrs) == 0 {
+		return ""
+	}
+	pairs := []string{}
+	for k, v := range attrs {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return " {" + strings.Join(pairs, " ") + "}"
+}
+
+// Parses src and returns every call to the handler in order, as
+// "node id {attrs}" and "src -> dest {attrs}"
+func parseEvents(src string) ([]string, error) {
+	events := []string{}
+	err := ParseDot(strings.NewReader(src), Handler{
+		Node: func(id string, attrs Attrs) {
+			events = append(events, "node "+id+formatAttrs(attrs))
+		},
+		Edge: func(src, dest string, attrs Attrs) {
+			events = append(events, src+" -> "+dest+formatAttrs(attrs))
+		},
+	})
+	return events, err
+}
+
+func TestParseDot(t *testing.T) {
+	for _, tc := range []struct {
+		name, src string
+		want      []string
+	}{
+		{
+			"edge chain",
+			`digraph { a -> b -> c; d }`,
+			[]string{"a -> b", "b -> c", "node d"},
+		},
+		{
+			"undirected graph",
+			`strict graph G { a -- b -- c }`,
+			[]string{"a -> b", "b -> c"},
+		},
+		{
+			"subgraph edges",
+			`digraph { a -> {b c}; subgraph s { d e } -> f -> {g} }`,
+			[]string{
+				"node b", "node c", "a -> b", "a -> c",
+				"node d", "node e", "node g", "d -> f", "e -> f", "f -> g",
+			},
+		},
+		{
+			"attribute lists",
+			`digraph { a -> b [weight=2, color="dark red"][style=bold; label=x]; c [shape] }`,
+			[]string{"a -> b {color=dark red label=x style=bold weight=2}", "node c {shape=true}"},
+		},
+		{
+			"default attributes",
+			`digraph {
+				node [shape=box]; edge [weight=2]
+				a -> b
+				subgraph { edge [weight=3]; node [color=red]; c; c -> d [label=x] }
+				edge [style=bold]
+				e; e -> f [weight=5]
+			}`,
+			[]string{
+				"a -> b {weight=2}",
+				"node c {color=red shape=box}",
+				"c -> d {label=x weight=3}",
+				"node e {shape=box}",
+				"e -> f {style=bold weight=5}",
+			},
+		},
+		{
+			"comments",
+			`# written by the crawler
+			digraph { // the whole graph
+				a -> b /* not
+				   a -> x */ -> c
+			# b -> y
+				c -> d
+			}`,
+			[]string{"a -> b", "b -> c", "c -> d"},
+		},
+		{
+			"quoted IDs",
+			`digraph { "a b" -> "c" + "d"; "say \"hi\"" -> "x\
+y" }`,
+			[]string{"a b -> cd", `say "hi" -> xy`},
+		},
+		{
+			"bare URLs",
+			`digraph {
+				rankdir=LR
+				https://a.edu/x?q=1&r=2 -> http://b.edu:8080/p;
+				mailto:a@b.edu -> a:p1:n -> b
+			}`,
+			[]string{
+				"https://a.edu/x?q=1&r=2 -> http://b.edu:8080/p",
+				"mailto:a@b.edu -> a",
+				"a -> b",
+			},
+		},
+	} {
+		got, err := parseEvents(tc.src)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got\n%q\nwant\n%q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestParseDotSyntaxError(t *testing.T) {
+	for _, tc := range []struct {
+		name, src string
+		line      int
+	}{
+		{"not a graph", "dag {\n}", 1},
+		{"missing endpoint", "digraph {\n a ->\n}", 3},
+		{"unterminated string", "digraph {\n a -> b\n \"c -> d\n}", 3},
+		{"unterminated comment", "digraph {\n a -> b /* never\n closed\n", 2},
+		{"wrong edge operator", "graph {\n a -- b\n b -> c\n}", 3},
+		{"attribute without value", "digraph {\n a -> b\n c [shape=]\n}", 3},
+		{"text after the graph", "digraph {\n a -> b\n}\nextra", 4},
+	} {
+		_, err := parseEvents(tc.src)
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("%s: got %v, want a *SyntaxError", tc.name, err)
+			continue
+		}
+		if syntaxErr.Line != tc.line {
+			t.Errorf("%s: error on line %d, want %d: %v", tc.name, syntaxErr.Line, tc.line, err)
+		}
+	}
+}
+
+func TestParseDotFiles(t *testing.T) {
+	// The crawls hold one edge per line and no node statements, except
+	// that a link in calpoly.gv was written with a space, "...?subject=Group
+	// Tour", which leaves Tour as a node on its own
+	for name, want := range map[string]struct{ nodes, edges int }{
+		"auth.gv":    {0, 13528},
+		"calpoly.gv": {1, 27326},
+		"graph.gv":   {0, 19090},
+		"test.gv":    {0, 3},
+	} {
+		file, err := os.Open("../dot_files/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nodes, edges := 0, 0
+		err = ParseDot(file, Handler{
+			Node: func(id string, attrs Attrs) { nodes++ },
+			Edge: func(src, dest string, attrs Attrs) { edges++ },
+		})
+		file.Close()
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if nodes != want.nodes || edges != want.edges {
+			t.Errorf("%s: %d nodes and %d edges, want %d and %d", name, nodes, edges, want.nodes, want.edges)
+		}
+	}
+}
@@ -0,0 +1,522 @@
+package pagerank
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Names of the supported ranking formats
+const (
+	// One JSON object per line
+	RankFormatJSON = "jsonl"
+	// Comma separated values with a header line
+	RankFormatCSV = "csv"
+	// "rank<TAB>url<TAB>score" lines, as written by WriteRanks, followed
+	// by "<TAB>hub<TAB>authority" when the rows hold HITS scores
+	RankFormatTSV = "tsv"
+	// Compact binary file that stores every field as its own column
+	RankFormatColumnar = "columnar"
+)
+
+// Row is one line of an exported ranking
+type Row struct {
+	// Position in the ranking, starting at one
+	Rank      int     `json:"rank"`
+	URL       string  `json:"url"`
+	Score     float64 `json:"score"`
+	InDegree  int     `json:"in_degree"`
+	OutDegree int     `json:"out_degree"`
+	Domain    string  `json:"domain"`
+	// HITS scores, zero unless set with SetHITS
+	Hub       float64 `json:"hub,omitempty"`
+	Authority float64 `json:"authority,omitempty"`
+}
+
+// SetHITS fills in the hub and authority scores of rows. URLs without a
+// score keep zero.
+func SetHITS(rows []Row, hubs, authorities Ranks) {
+	for idx := range rows {
+		rows[idx].Hub = hubs[rows[idx].URL]
+		rows[idx].Authority = authorities[rows[idx].URL]
+	}
+}
+
+// Reports whether any of rows holds HITS scores. Only then do the
+// formats write the hub and authority columns, so rankings without them
+// keep their old layout.
+func hasHITS(rows []Row) bool {
+	for _, row := range rows {
+		if row.Hub != 0 || row.Authority != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Rows returns the num best ranked URLs of ranks as rows, best first.
+// The degrees are taken from c, which may be nil to leave them at zero.
+// A num below one returns the full ranking.
+func Rows(ranks Ranks, c *CSR, num int) []Row {
+	if num < 1 {
+		num = len(ranks)
+	}
+	var inDegree []uint32
+	if c != nil {
+		inDegree = make([]uint32, c.NumNodes())
+		for id := range inDegree {
+			inDegree[id] = c.InOffsets[id+1] - c.InOffsets[id]
+		}
+	}
+	top := ranks.Top(num)
+	rows := make([]Row, len(top))
+	for idx, p := range top {
+		domain, _ := Domain(p.URL)
+		rows[idx] = Row{Rank: idx + 1, URL: p.URL, Score: p.PageRank, Domain: domain}
+		if c != nil {
+			if id, ok := c.ID(p.URL); ok {
+				rows[idx].InDegree = int(inDegree[id])
+				rows[idx].OutDegree = int(c.OutDegree[id])
+			}
+		}
+	}
+	return rows
+}
+
+// Maps a file extension to its ranking format
+var rankExtensions = map[string]string{
+	".jsonl": RankFormatJSON,
+	".json":  RankFormatJSON,
+	".csv":   RankFormatCSV,
+	".tsv":   RankFormatTSV,
+	".txt":   RankFormatTSV,
+	".col":   RankFormatColumnar,
+	".prc":   RankFormatColumnar,
+}
+
+// DetectRankFormat returns the ranking format of path from its extension
+func DetectRankFormat(path string) (string, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	format, ok := rankExtensions[ext]
+	if !ok {
+		return "", fmt.Errorf("%s: cannot tell the ranking format from the extension %q", path, ext)
+	}
+	return format, nil
+}
+
+// WriteRows writes rows to w in the given ranking format
+func WriteRows(w io.Writer, format string, rows []Row) error {
+	buf := bufio.NewWriter(w)
+	hits := hasHITS(rows)
+	var err error
+	switch format {
+	case RankFormatJSON:
+		enc := json.NewEncoder(buf)
+		for _, row := range rows {
+			if err = enc.Encode(row); err != nil {
+				break
+			}
+		}
+	case RankFormatCSV:
+		cw := csv.NewWriter(buf)
+		header := []string{"rank", "url", "score", "in_degree", "out_degree", "domain"}
+		if hits {
+			header = append(header, "hub", "authority")
+		}
+		cw.Write(header)
+		for _, row := range rows {
+			record := []string{
+				strconv.Itoa(row.Rank),
+				row.URL,
+				formatScore(row.Score),
+				strconv.Itoa(row.InDegree),
+				strconv.Itoa(row.OutDegree),
+				row.Domain,
+			}
+			if hits {
+				record = append(record, formatScore(row.Hub), formatScore(row.Authority))
+			}
+			cw.Write(record)
+		}
+		cw.Flush()
+		err = cw.Error()
+	case RankFormatTSV:
+		for _, row := range rows {
+			if hits {
+				fmt.Fprintf(buf, "%d\t%s\t%s\t%s\t%s\n", row.Rank, row.URL, formatScore(row.Score), formatScore(row.Hub), formatScore(row.Authority))
+			} else {
+				fmt.Fprintf(buf, "%d\t%s\t%s\n", row.Rank, row.URL, formatScore(row.Score))
+			}
+		}
+	case RankFormatColumnar:
+		err = writeColumnar(buf, rows, hits)
+	default:
+		return fmt.Errorf("unknown ranking format %q", format)
+	}
+	if err != nil {
+		return err
+	}
+	return buf.Flush()
+}
+
+// Formats a score with as many digits as it takes to read it back
+// exactly, so comparisons and warm starts see the computed values
+func formatScore(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// WriteRowsFile writes rows to path. An empty format is detected from
+// the extension.
+func WriteRowsFile(path, format string, rows []Row) error {
+	if format == "" {
+		var err error
+		if format, err = DetectRankFormat(path); err != nil {
+			return err
+		}
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := WriteRows(file, format, rows); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// ReadRows reads rows in the given ranking format from r
+func ReadRows(r io.Reader, format string) ([]Row, error) {
+	rows := []Row{}
+	switch format {
+	case RankFormatJSON:
+		dec := json.NewDecoder(r)
+		for line := 1; ; line++ {
+			var row Row
+			if err := dec.Decode(&row); err == io.EOF {
+				return rows, nil
+			} else if err != nil {
+				return nil, &SyntaxError{Line: line, Msg: err.Error()}
+			}
+			rows = append(rows, row)
+		}
+	case RankFormatCSV:
+		cr := csv.NewReader(r)
+		records, err := cr.ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		// The header tells whether the hub and authority columns follow
+		fields := 6
+		if len(records) > 0 && len(records[0]) == 8 {
+			fields = 8
+		}
+		for idx, record := range records {
+			if idx == 0 {
+				continue
+			}
+			if len(record) != fields {
+				return nil, &SyntaxError{Line: idx + 1, Msg: fmt.Sprintf("expected %d fields, found %d", fields, len(record))}
+			}
+			row, err := parseRow(record[0], record[1], record[2], record[3], record[4], record[5])
+			if err == nil && fields == 8 {
+				err = parseHITS(&row, record[6], record[7])
+			}
+			if err != nil {
+				return nil, &SyntaxError{Line: idx + 1, Msg: err.Error()}
+			}
+			rows = append(rows, row)
+		}
+		return rows, nil
+	case RankFormatTSV:
+		scanner := bufio.NewScanner(r)
+		for line := 1; scanner.Scan(); line++ {
+			fields := strings.Split(scanner.Text(), "\t")
+			if len(fields) != 3 && len(fields) != 5 {
+				return nil, &SyntaxError{Line: line, Msg: "expected \"rank<TAB>url<TAB>score[<TAB>hub<TAB>authority]\""}
+			}
+			domain, _ := Domain(fields[1])
+			row, err := parseRow(fields[0], fields[1], fields[2], "0", "0", domain)
+			if err == nil && len(fields) == 5 {
+				err = parseHITS(&row, fields[3], fields[4])
+			}
+			if err != nil {
+				return nil, &SyntaxError{Line: line, Msg: err.Error()}
+			}
+			rows = append(rows, row)
+		}
+		return rows, scanner.Err()
+	case RankFormatColumnar:
+		return readColumnar(r)
+	}
+	return nil, fmt.Errorf("unknown ranking format %q", format)
+}
+
+// ReadRowsFile reads the ranking at path. An empty format is detected
+// from the extension.
+func ReadRowsFile(path, format string) ([]Row, error) {
+	if format == "" {
+		var err error
+		if format, err = DetectRankFormat(path); err != nil {
+			return nil, err
+		}
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	rows, err := ReadRows(bufio.NewReader(file), format)
+	if syntaxErr, ok := err.(*SyntaxError); ok {
+		syntaxErr.Path = path
+	}
+	return rows, err
+}
+
+// RowRanks returns the scores of rows keyed by URL
+func RowRanks(rows []Row) Ranks {
+	ranks := make(Ranks, len(rows))
+	for _, row := range rows {
+		ranks[row.URL] = row.Score
+	}
+	return ranks
+}
+
+func parseRow(rank, url, score, in, out, domain string) (Row, error) {
+	row := Row{URL: url, Domain: domain}
+	var err error
+	if row.Rank, err = strconv.Atoi(rank); err != nil {
+		return row, fmt.Errorf("bad rank %q", rank)
+	}
+	if row.Score, err = strconv.ParseFloat(score, 64); err != nil {
+		return row, fmt.Errorf("bad score %q", score)
+	}
+	if row.InDegree, err = strconv.Atoi(in); err != nil {
+		return row, fmt.Errorf("bad in-degree %q", in)
+	}
+	if row.OutDegree, err = strconv.Atoi(out); err != nil {
+		return row, fmt.Errorf("bad out-degree %q", out)
+	}
+	return row, nil
+}
+
+func parseHITS(row *Row, hub, authority string) error {
+	var err error
+	if row.Hub, err = strconv.ParseFloat(hub, 64); err != nil {
+		return fmt.Errorf("bad hub score %q", hub)
+	}
+	if row.Authority, err = strconv.ParseFloat(authority, 64); err != nil {
+		return fmt.Errorf("bad authority score %q", authority)
+	}
+	return nil
+}
+
+// The columnar file starts with this magic and a row count. Then every
+// field follows as one little endian column:
+//
+//	rank       uint32 x rows
+//	score      float64 x rows
+//	in_degree  uint32 x rows
+//	out_degree uint32 x rows
+//	url        uint32 end offsets x rows, then the bytes of every URL
+//	domain     uint32 dictionary size, each entry as a uint32 length and
+//	           its bytes, then a uint32 dictionary index x rows
+//
+// Rankings with HITS scores start with the second magic instead, and
+// hold a hub and an authority float64 column after the scores.
+var (
+	columnarMagic     = []byte("PRCOL1\n")
+	columnarHITSMagic = []byte("PRCOL2\n")
+)
+
+var errColumnar = errors.New("not a columnar ranking file")
+
+func writeColumnar(w io.Writer, rows []Row, hits bool) error {
+	le := binary.LittleEndian
+	n := len(rows)
+	put := func(v interface{}) error {
+		return binary.Write(w, le, v)
+	}
+	magic := columnarMagic
+	if hits {
+		magic = columnarHITSMagic
+	}
+	if _, err := w.Write(magic); err != nil {
+		return err
+	}
+	ranks := make([]uint32, n)
+	scores := make([]float64, n)
+	hubs := make([]float64, n)
+	authorities := make([]float64, n)
+	in := make([]uint32, n)
+	out := make([]uint32, n)
+	ends := make([]uint32, n)
+	var urls strings.Builder
+	dict := []string{}
+	dictIndex := make(map[string]uint32)
+	domains := make([]uint32, n)
+	for i, row := range rows {
+		ranks[i] = uint32(row.Rank)
+		scores[i] = row.Score
+		hubs[i] = row.Hub
+		authorities[i] = row.Authority
+		in[i] = uint32(row.InDegree)
+		out[i] = uint32(row.OutDegree)
+		urls.WriteString(row.URL)
+		ends[i] = uint32(urls.Len())
+		idx, ok := dictIndex[row.Domain]
+		if !ok {
+			idx = uint32(len(dict))
+			dictIndex[row.Domain] = idx
+			dict = append(dict, row.Domain)
+		}
+		domains[i] = idx
+	}
+	columns := []interface{}{uint32(n), ranks, scores, in, out, ends}
+	if hits {
+		columns = []interface{}{uint32(n), ranks, scores, hubs, authorities, in, out, ends}
+	}
+	for _, v := range columns {
+		if err := put(v); err != nil {
+			return err
+		}
+	}
+	if _, err := io.WriteString(w, urls.String()); err != nil {
+		return err
+	}
+	if err := put(uint32(len(dict))); err != nil {
+		return err
+	}
+	for _, domain := range dict {
+		if err := put(uint32(len(domain))); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, domain); err != nil {
+			return err
+		}
+	}
+	return put(domains)
+}
+
+func readColumnar(r io.Reader) ([]Row, error) {
+	le := binary.LittleEndian
+	get := func(v interface{}) error {
+		if err := binary.Read(r, le, v); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return errColumnar
+			}
+			return err
+		}
+		return nil
+	}
+	magic := make([]byte, len(columnarMagic))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return nil, errColumnar
+	}
+	hits := string(magic) == string(columnarHITSMagic)
+	if !hits && string(magic) != string(columnarMagic) {
+		return nil, errColumnar
+	}
+	var n uint32
+	if err := get(&n); err != nil {
+		return nil, err
+	}
+	// Guard against allocating huge columns for a corrupt count
+	if n > math.MaxInt32/8 {
+		return nil, errColumnar
+	}
+	ranks := make([]uint32, n)
+	scores := make([]float64, n)
+	hubs := make([]float64, n)
+	authorities := make([]float64, n)
+	in := make([]uint32, n)
+	out := make([]uint32, n)
+	ends := make([]uint32, n)
+	columns := []interface{}{ranks, scores, in, out, ends}
+	if hits {
+		columns = []interface{}{ranks, scores, hubs, authorities, in, out, ends}
+	}
+	for _, v := range columns {
+		if err := get(v); err != nil {
+			return nil, err
+		}
+	}
+	size := uint32(0)
+	if n > 0 {
+		size = ends[n-1]
+	}
+	urls := make([]byte, size)
+	if _, err := io.ReadFull(r, urls); err != nil {
+		return nil, errColumnar
+	}
+	var dictSize uint32
+	if err := get(&dictSize); err != nil {
+		return nil, err
+	}
+	if dictSize > n+1 {
+		return nil, errColumnar
+	}
+	dict := make([]string, dictSize)
+	for i := range dict {
+		var length uint32
+		if err := get(&length); err != nil {
+			return nil, err
+		}
+		if length > size+1024 {
+			return nil, errColumnar
+		}
+		b := make([]byte, length)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, errColumnar
+		}
+		dict[i] = string(b)
+	}
+	domains := make([]uint32, n)
+	if err := get(domains); err != nil {
+		return nil, err
+	}
+	rows := make([]Row, n)
+	start := uint32(0)
+	for i := range rows {
+		if ends[i] < start || ends[i] > size || domains[i] >= dictSize {
+			return nil, errColumnar
+		}
+		rows[i] = Row{
+			Rank:      int(ranks[i]),
+			URL:       string(urls[start:ends[i]]),
+			Score:     scores[i],
+			Hub:       hubs[i],
+			Authority: authorities[i],
+			InDegree:  int(in[i]),
+			OutDegree: int(out[i]),
+			Domain:    dict[domains[i]],
+		}
+		start = ends[i]
+	}
+	return rows, nil
+}
+
+// WriteRanks writes the num best ranked URLs to w, best first, as
+// "rank<TAB>url<TAB>score" lines. A num below one writes every URL.
+func WriteRanks(w io.Writer, ranks Ranks, num int) error {
+	return WriteRows(w, RankFormatTSV, Rows(ranks, nil, num))
+}
+
+// ReadRanks reads the scores of a ranking file in any ranking format,
+// detected from the extension
+func ReadRanks(path string) (Ranks, error) {
+	rows, err := ReadRowsFile(path, "")
+	if err != nil {
+		return nil, err
+	}
+	return RowRanks(rows), nil
+}
@@ -0,0 +1,53 @@
+package pagerank
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"../canonical"
+)
+
+func TestWriteReadRowsRoundTrip(t *testing.T) {
+	graph, err := ReadCSR(writeGraph(t, "crawl.gv", testDot), "", canonical.Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := ComputeCSR(graph, DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ranks := graph.Ranks(result.Ranks)
+	plain := Rows(ranks, graph, 0)
+	hits := Rows(ranks, graph, 0)
+	hubs, authorities := Ranks{}, Ranks{}
+	for url, rank := range ranks {
+		hubs[url] = rank / 3
+		authorities[url] = 1 - rank
+	}
+	SetHITS(hits, hubs, authorities)
+
+	for _, format := range []string{RankFormatJSON, RankFormatCSV, RankFormatTSV, RankFormatColumnar} {
+		for name, rows := range map[string][]Row{"page rank": plain, "hits": hits} {
+			var buf bytes.Buffer
+			if err := WriteRows(&buf, format, rows); err != nil {
+				t.Fatalf("%s, %s: %v", format, name, err)
+			}
+			got, err := ReadRows(&buf, format)
+			if err != nil {
+				t.Fatalf("%s, %s: %v", format, name, err)
+			}
+			want := rows
+			if format == RankFormatTSV {
+				// TSV has no degree columns
+				want = append([]Row{}, rows...)
+				for idx := range want {
+					want[idx].InDegree, want[idx].OutDegree = 0, 0
+				}
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%s, %s: read back\n%v\nwant\n%v", format, name, got, want)
+			}
+		}
+	}
+}
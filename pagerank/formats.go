@@ -0,0 +1,354 @@
+package pagerank
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"../canonical"
+)
+
+// Names of the supported input formats
+const (
+	// Graphviz dot files, as written by the crawler
+	FormatDot = "dot"
+	// One "src dest [weight]" link per line, separated by white space
+	FormatEdgeList = "edgelist"
+	// Edge lists from the Stanford Network Analysis Project, with a
+	// header of # comments
+	FormatSNAP = "snap"
+	// One "src dest1 dest2 ..." line per node
+	FormatAdjacency = "adjlist"
+	// Matrix Market coordinate files, where entry (i, j) is a link
+	// from node i to node j
+	FormatMatrixMarket = "mtx"
+)
+
+// GraphReader reads a link graph in one input format and calls h for
+// every node and edge in it
+type GraphReader interface {
+	Read(r io.Reader, h Handler) error
+}
+
+// GraphReaderFunc adapts a function to the GraphReader interface
+type GraphReaderFunc func(r io.Reader, h Handler) error
+
+// Read calls f(r, h)
+func (f GraphReaderFunc) Read(r io.Reader, h Handler) error {
+	return f(r, h)
+}
+
+var readers = map[string]GraphReader{
+	FormatDot:          GraphReaderFunc(ParseDot),
+	FormatEdgeList:     GraphReaderFunc(readEdgeList),
+	FormatSNAP:         GraphReaderFunc(readEdgeList),
+	FormatAdjacency:    GraphReaderFunc(readAdjacencyList),
+	FormatMatrixMarket: GraphReaderFunc(readMatrixMarket),
+}
+
+// Maps a file extension to its input format
+var extensions = map[string]string{
+	".gv":       FormatDot,
+	".dot":      FormatDot,
+	".txt":      FormatEdgeList,
+	".tsv":      FormatEdgeList,
+	".el":       FormatEdgeList,
+	".edges":    FormatEdgeList,
+	".edgelist": FormatEdgeList,
+	".snap":     FormatSNAP,
+	".adj":      FormatAdjacency,
+	".adjlist":  FormatAdjacency,
+	".mtx":      FormatMatrixMarket,
+}
+
+// RegisterReader makes r available under the format name
+func RegisterReader(format string, r GraphReader) {
+	readers[format] = r
+}
+
+// Formats returns the names of all input formats
+func Formats() []string {
+	formats := make([]string, 0, len(readers))
+	for format := range readers {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// DetectFormat returns the input format of path from its extension,
+// ignoring a trailing .gz
+func DetectFormat(path string) (string, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == ".gz" {
+		ext = strings.ToLower(filepath.Ext(strings.TrimSuffix(path, filepath.Ext(path))))
+	}
+	format, ok := extensions[ext]
+	if !ok {
+		return "", fmt.Errorf("%s: cannot tell the input format from the extension %q", path, ext)
+	}
+	return format, nil
+}
+
+// ScanFile reads the graph at path and calls h for every node and edge,
+// with the URLs rewritten by rules, so URLs such as
+// "https://www.calpoly.edu#primary" and "https://www.calpoly.edu/" become
+// the same node. An empty format is detected from the extension, and gzip
+// compressed files are decompressed transparently.
+func ScanFile(path, format string, rules canonical.Rules, h Handler) error {
+	if format == "" {
+		var err error
+		if format, err = DetectFormat(path); err != nil {
+			return err
+		}
+	}
+	reader, ok := readers[format]
+	if !ok {
+		return fmt.Errorf("unknown input format %q, want one of %s", format, strings.Join(Formats(), ", "))
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var r io.Reader = bufio.NewReader(file)
+	// Gzip files start with the bytes 1f 8b
+	if magic, _ := r.(*bufio.Reader).Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	err = reader.Read(r, canonicalHandler(h, rules))
+	if syntaxErr, ok := err.(*SyntaxError); ok {
+		syntaxErr.Path = path
+	}
+	return err
+}
+
+// Returns a handler that passes the IDs rewritten by rules on to h
+func canonicalHandler(h Handler, rules canonical.Rules) Handler {
+	if rules == canonical.None {
+		return h
+	}
+	c := Handler{}
+	if h.Node != nil {
+		c.Node = func(id string, attrs Attrs) {
+			h.Node(rules.URL(id), attrs)
+		}
+	}
+	if h.Edge != nil {
+		c.Edge = func(src, dest string, attrs Attrs) {
+			h.Edge(rules.URL(src), rules.URL(dest), attrs)
+		}
+	}
+	return c
+}
+
+// Calls f with the fields of every line of r that is not blank or a
+// comment starting with one of the characters in comments
+func scanLines(r io.Reader, comments string, f func(line int, fields []string) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.ContainsRune(comments, rune(text[0])) {
+			continue
+		}
+		if err := f(line, strings.Fields(text)); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// Reads edge lists and SNAP files. A third column is kept as the
+// weight attribute of the edge.
+func readEdgeList(r io.Reader, h Handler) error {
+	return scanLines(r, "#%", func(line int, fields []string) error {
+		if len(fields) < 2 || len(fields) > 3 {
+			return &SyntaxError{Line: line, Msg: fmt.Sprintf("expected \"src dest [weight]\", found %d fields", len(fields))}
+		}
+		var attrs Attrs
+		if len(fields) == 3 {
+			if _, err := strconv.ParseFloat(fields[2], 64); err != nil {
+				return &SyntaxError{Line: line, Msg: fmt.Sprintf("bad weight %q", fields[2])}
+			}
+			attrs = Attrs{"weight": fields[2]}
+		}
+		if h.Edge != nil {
+			h.Edge(fields[0], fields[1], attrs)
+		}
+		return nil
+	})
+}
+
+// Reads adjacency lists. The source may end with a ':', and a line with
+// only a source is a node without outlinks.
+func readAdjacencyList(r io.Reader, h Handler) error {
+	return scanLines(r, "#%", func(line int, fields []string) error {
+		src := strings.TrimSuffix(fields[0], ":")
+		if src == "" {
+			return &SyntaxError{Line: line, Msg: "missing source node"}
+		}
+		if h.Node != nil {
+			h.Node(src, nil)
+		}
+		if h.Edge != nil {
+			for _, dest := range fields[1:] {
+				h.Edge(src, dest, nil)
+			}
+		}
+		return nil
+	})
+}
+
+// Reads Matrix Market coordinate files. Every index up to the matrix
+// size is a node, and the value of an entry is kept as the weight
+// attribute of the edge. Nodes are named by their one based index,
+// unless the comments before the size line name every one of them with
+// "% index name" lines, as the Matrix Market writer does.
+func readMatrixMarket(r io.Reader, h Handler) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	errorf := func(format string, args ...interface{}) error {
+		return &SyntaxError{Line: line, Msg: fmt.Sprintf(format, args...)}
+	}
+	// %%MatrixMarket matrix coordinate <field> <symmetry>
+	line++
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+		return errorf("missing %%%%MatrixMarket header")
+	}
+	header := strings.Fields(strings.ToLower(scanner.Text()))
+	if len(header) != 5 || header[0] != "%%matrixmarket" || header[1] != "matrix" {
+		return errorf("missing %%%%MatrixMarket matrix header")
+	}
+	if header[2] != "coordinate" {
+		return errorf("only coordinate matrices are supported, found %q", header[2])
+	}
+	pattern := header[3] == "pattern"
+	if !pattern && header[3] != "real" && header[3] != "integer" {
+		return errorf("unsupported field %q", header[3])
+	}
+	symmetric := header[4] == "symmetric"
+	if !symmetric && header[4] != "general" {
+		return errorf("unsupported symmetry %q", header[4])
+	}
+
+	size := 0
+	entries, expected := 0, -1
+	// Names given by the comments, and the name of every node once they
+	// turn out to name them all
+	commented := make(map[int]string)
+	var names []string
+	name := func(idx int) string {
+		if names != nil {
+			return names[idx-1]
+		}
+		return strconv.Itoa(idx)
+	}
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		if text[0] == '%' {
+			if expected < 0 {
+				fields := strings.SplitN(strings.TrimSpace(text[1:]), " ", 2)
+				if idx, err := strconv.Atoi(fields[0]); err == nil && idx > 0 && len(fields) == 2 {
+					commented[idx] = strings.TrimSpace(fields[1])
+				}
+			}
+			continue
+		}
+		fields := strings.Fields(text)
+		nums := make([]int, 2)
+		for i := 0; i < 2 && i < len(fields); i++ {
+			n, err := strconv.Atoi(fields[i])
+			if err != nil || n < 1 {
+				return errorf("bad index %q", fields[i])
+			}
+			nums[i] = n
+		}
+		if expected < 0 {
+			// Size line: rows columns entries
+			if len(fields) != 3 {
+				return errorf("expected \"rows columns entries\", found %d fields", len(fields))
+			}
+			count, err := strconv.Atoi(fields[2])
+			if err != nil || count < 0 {
+				return errorf("bad entry count %q", fields[2])
+			}
+			size, expected = nums[0], count
+			if nums[1] > size {
+				size = nums[1]
+			}
+			if len(commented) == size {
+				names = make([]string, size)
+				for idx, url := range commented {
+					if idx > size || url == "" {
+						names = nil
+						break
+					}
+					names[idx-1] = url
+				}
+			}
+			if h.Node != nil {
+				for i := 1; i <= size; i++ {
+					h.Node(name(i), nil)
+				}
+			}
+			continue
+		}
+		want := 3
+		if pattern {
+			want = 2
+		}
+		if len(fields) != want {
+			return errorf("expected %d fields, found %d", want, len(fields))
+		}
+		if nums[0] > size || nums[1] > size {
+			return errorf("entry (%d, %d) is outside the %d x %d matrix", nums[0], nums[1], size, size)
+		}
+		var attrs Attrs
+		if !pattern {
+			if _, err := strconv.ParseFloat(fields[2], 64); err != nil {
+				return errorf("bad value %q", fields[2])
+			}
+			attrs = Attrs{"weight": fields[2]}
+		}
+		entries++
+		if h.Edge != nil {
+			src, dest := name(nums[0]), name(nums[1])
+			h.Edge(src, dest, attrs)
+			if symmetric && src != dest {
+				h.Edge(dest, src, attrs)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if expected < 0 {
+		return errorf("missing size line")
+	}
+	if entries != expected {
+		return errorf("expected %d entries, found %d", expected, entries)
+	}
+	return nil
+}
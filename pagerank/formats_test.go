@@ -0,0 +1,109 @@
+package pagerank
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"../canonical"
+)
+
+// Returns every edge of g as "src -> dest", sorted
+func edgeList(g *Graph) []string {
+	edges := []string{}
+	for dest, sources := range g.AdjacencyList {
+		for _, src := range sources {
+			edges = append(edges, src+" -> "+dest)
+		}
+	}
+	sort.Strings(edges)
+	return edges
+}
+
+func TestWriteReadRoundTrip(t *testing.T) {
+	graph, err := ReadGraph(writeGraph(t, "crawl.gv", testDot), "", canonical.Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := edgeList(graph)
+	nodes := append([]string{}, graph.Nodes...)
+	sort.Strings(nodes)
+	for _, name := range []string{"out.gv", "out.txt", "out.snap", "out.adj", "out.mtx", "out.mtx.gz"} {
+		path := filepath.Join(t.TempDir(), name)
+		if err := WriteFile(path, "", graph); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		back, err := ReadGraph(path, "", canonical.Default)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got := edgeList(back); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: edges %v, want %v", name, got, want)
+		}
+		got := append([]string{}, back.Nodes...)
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, nodes) {
+			t.Errorf("%s: nodes %v, want %v", name, got, nodes)
+		}
+	}
+}
+
+func TestReadMatrixMarketNames(t *testing.T) {
+	for _, tc := range []struct {
+		name, content string
+		want          []string
+	}{
+		{
+			"every node named",
+			"%%MatrixMarket matrix coordinate real general\n% 1 https://a.edu\n% 2 https://b.edu\n2 2 1\n1 2 0.5\n",
+			[]string{"https://a.edu -> https://b.edu"},
+		},
+		{
+			"ordinary comment",
+			"%%MatrixMarket matrix coordinate pattern general\n% 2 dimensional example\n2 2 1\n1 2\n",
+			[]string{"1 -> 2"},
+		},
+		{
+			"some nodes named",
+			"%%MatrixMarket matrix coordinate pattern general\n% 1 https://a.edu\n3 3 1\n1 2\n",
+			[]string{"1 -> 2"},
+		},
+	} {
+		graph, err := ReadGraph(writeGraph(t, "graph.mtx", tc.content), "", canonical.Default)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if got := edgeList(graph); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: edges %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestScanFileRules(t *testing.T) {
+	path := writeGraph(t, "crawl.gv", `digraph {
+"http://a.example.edu/" -> "https://a.example.edu#top";
+"https://a.example.edu" -> "https://B.example.edu/x/";
+}`)
+	for _, tc := range []struct {
+		rules canonical.Rules
+		want  []string
+	}{
+		{canonical.None, []string{
+			"http://a.example.edu/ -> https://a.example.edu#top",
+			"https://a.example.edu -> https://B.example.edu/x/",
+		}},
+		{canonical.Default, []string{
+			"https://a.example.edu -> https://a.example.edu",
+			"https://a.example.edu -> https://b.example.edu/x",
+		}},
+	} {
+		graph, err := ReadGraph(path, "", tc.rules)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := edgeList(graph); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("rules %v: edges %v, want %v", tc.rules, got, tc.want)
+		}
+	}
+}
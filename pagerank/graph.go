@@ -0,0 +1,168 @@
+// Package pagerank holds the graph model and the page rank computation
+// shared by the sequential and distributed programs.
+package pagerank
+
+import "../canonical"
+
+// Graph holds all information about a link graph or one of its subgraphs
+type Graph struct {
+	// Name of the domain when the graph is a subgraph, empty otherwise
+	Name string
+	// List of all the nodes
+	Nodes []string
+	// Maps a node to a list of incoming nodes
+	AdjacencyList map[string][]string
+	// Maps a node to its number of outlinks
+	OutLinks map[string]int
+	// Attributes from the dot file, only kept for nodes and edges that
+	// have any
+	NodeAttrs map[string]Attrs
+	EdgeAttrs map[Edge]Attrs
+	// Map to keep track if we have seen node before
+	visited map[string]bool
+}
+
+// NewGraph returns an empty graph with the given name.
+func NewGraph(name string) *Graph {
+	g := new(Graph)
+	g.Name = name
+	g.AdjacencyList = make(map[string][]string)
+	g.OutLinks = make(map[string]int)
+	g.NodeAttrs = make(map[string]Attrs)
+	g.EdgeAttrs = make(map[Edge]Attrs)
+	g.visited = make(map[string]bool)
+	return g
+}
+
+// AddNode adds url to the node list if we have not come across it before
+func (g *Graph) AddNode(url string) {
+	if !g.visited[url] {
+		g.visited[url] = true
+		g.Nodes = append(g.Nodes, url)
+	}
+}
+
+// HasNode reports whether url is part of the graph
+func (g *Graph) HasNode(url string) bool {
+	return g.visited[url]
+}
+
+// AddEdge records a link from src to dest, adding both nodes if needed
+func (g *Graph) AddEdge(src, dest string) {
+	if !g.visited[src] {
+		g.AddNode(src)
+		g.OutLinks[src] = 0
+	}
+	g.AddNode(dest)
+	// Add to adjacencyList
+	g.AdjacencyList[dest] = append(g.AdjacencyList[dest], src)
+	// Add to outLinks
+	g.OutLinks[src]++
+}
+
+// Edge is a link from Src to Dest
+type Edge struct {
+	Src  string
+	Dest string
+}
+
+// Records the attributes of url, merging them with earlier ones
+func (g *Graph) setNodeAttrs(url string, attrs Attrs) {
+	if len(attrs) > 0 {
+		g.NodeAttrs[url] = mergeAttrs(g.NodeAttrs[url], attrs)
+	}
+}
+
+// Records the attributes of a link, merging them with earlier ones
+func (g *Graph) setEdgeAttrs(src, dest string, attrs Attrs) {
+	if len(attrs) > 0 {
+		e := Edge{src, dest}
+		g.EdgeAttrs[e] = mergeAttrs(g.EdgeAttrs[e], attrs)
+	}
+}
+
+// ReadDotFile reads the dot file at path and fills out the nodes,
+// adjacency list and outlinks of a new graph, with the URLs in the
+// canonical.Default form.
+func ReadDotFile(path string) (*Graph, error) {
+	return ReadGraph(path, FormatDot, canonical.Default)
+}
+
+// ReadGraph is like ReadDotFile for any input format and canonicalization
+// rules, see ScanFile
+func ReadGraph(path, format string, rules canonical.Rules) (*Graph, error) {
+	g := NewGraph("")
+	err := ScanFile(path, format, rules, Handler{
+		Node: func(url string, attrs Attrs) {
+			g.AddNode(url)
+			g.setNodeAttrs(url, attrs)
+		},
+		Edge: func(src, dest string, attrs Attrs) {
+			g.AddEdge(src, dest)
+			g.setEdgeAttrs(src, dest, attrs)
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// ReadDotFileByDomain is like ReadDotFile but only keeps the links
+// whose source URL is part of domain.
+func ReadDotFileByDomain(path string, domain string) (*Graph, error) {
+	return ReadGraphByDomain(path, FormatDot, canonical.Default, domain)
+}
+
+// ReadGraphByDomain is like ReadDotFileByDomain for any input format and
+// canonicalization rules
+func ReadGraphByDomain(path, format string, rules canonical.Rules, domain string) (*Graph, error) {
+	g := NewGraph(domain)
+	err := ScanFile(path, format, rules, Handler{
+		Node: func(url string, attrs Attrs) {
+			if IsDomain(url, domain) {
+				g.AddNode(url)
+				g.setNodeAttrs(url, attrs)
+			}
+		},
+		Edge: func(src, dest string, attrs Attrs) {
+			// Check if the source link is part of the domain
+			if IsDomain(src, domain) {
+				g.AddEdge(src, dest)
+				g.setEdgeAttrs(src, dest, attrs)
+			}
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Combine merges subgraphs into one global graph. Every link is kept,
+// including the links between domains, so a link whose source and
+// destination are in different subgraphs still counts as an in-link.
+func Combine(subgraphs []*Graph) *Graph {
+	global := NewGraph("")
+	for _, subgraph := range subgraphs {
+		// Combine the nodes list
+		for _, url := range subgraph.Nodes {
+			global.AddNode(url)
+		}
+		// Combine the adjacencyList and outLinks maps. Each link is only
+		// part of the subgraph of its source, so nothing is counted twice.
+		for url, value := range subgraph.AdjacencyList {
+			global.AdjacencyList[url] = append(global.AdjacencyList[url], value...)
+		}
+		for url, value := range subgraph.OutLinks {
+			global.OutLinks[url] += value
+		}
+		for url, attrs := range subgraph.NodeAttrs {
+			global.setNodeAttrs(url, attrs)
+		}
+		for e, attrs := range subgraph.EdgeAttrs {
+			global.setEdgeAttrs(e.Src, e.Dest, attrs)
+		}
+	}
+	return global
+}
@@ -0,0 +1,191 @@
+package pagerank
+
+import (
+	"math"
+	"strings"
+)
+
+// HITSResult holds the outcome of HITS
+type HITSResult struct {
+	// Hub and authority scores, indexed by node ID and summing to one each
+	Hubs        []float64
+	Authorities []float64
+	// Number of iterations until the scores converged
+	Iterations int
+	// Residual between consecutive iterations in Options.Norm, over the
+	// hub and authority scores together
+	History []float64
+}
+
+// HITS computes Kleinberg's hub and authority scores of c. A good hub
+// links to many good authorities and a good authority is linked from
+// many good hubs:
+//
+//	a(i) = SUM{j -> i}[h(j)]
+//	h(i) = SUM{i -> j}[a(j)]
+//
+// Both vectors start uniform and are normalized to sum to one after
+// every iteration, so index pages end up with high hub scores and the
+// content pages they point to with high authority scores. The iteration
+// uses the convergence controls of ComputeCSR: it stops once the
+// residual in Options.Norm falls below Options.Epsilon, and returns the
+// scores of the last iteration with a *ConvergenceError when
+// Options.MaxIterations runs out first. Options.Workers splits every
+// iteration like in ComputeCSR, and Options.Links merges parallel links
+// first; in the weighted mode every score in the sums counts with the
+// weight of its link. The damping, personalization and solver settings
+// do not apply.
+func HITS(c *CSR, opts Options) (*HITSResult, error) {
+	c = c.Collapse(opts.Links)
+	n := c.NumNodes()
+	result := &HITSResult{}
+	if n == 0 {
+		result.Hubs, result.Authorities = []float64{}, []float64{}
+		return result, nil
+	}
+	hubs, authorities := make([]float64, n), make([]float64, n)
+	uniform(hubs)
+	uniform(authorities)
+	newHubs, newAuthorities := make([]float64, n), make([]float64, n)
+	outOffsets, outLinks := outAdjacency(c)
+	linkWeights := outWeights(c, outOffsets)
+	pool := newNodePool(n, opts.Workers, opts.Norm)
+	for {
+		// Authorities collect the hub scores of their in-links
+		authorityTotal := pool.each(func(lo, hi int) partialSums {
+			var total accumulator
+			for i := lo; i < hi; i++ {
+				value := 0.0
+				w := c.InWeights(uint32(i))
+				for k, j := range c.In(uint32(i)) {
+					if w != nil {
+						value += w[k] * hubs[j]
+					} else {
+						value += hubs[j]
+					}
+				}
+				newAuthorities[i] = value
+				total.add(value)
+			}
+			return partialSums{total: total.value()}
+		}).total
+		// Hubs collect the new authority scores of their outlinks. The
+		// scale of the authorities does not matter, as both vectors are
+		// normalized afterwards.
+		hubTotal := pool.each(func(lo, hi int) partialSums {
+			var total accumulator
+			for i := lo; i < hi; i++ {
+				value := 0.0
+				for k := outOffsets[i]; k < outOffsets[i+1]; k++ {
+					if linkWeights != nil {
+						value += linkWeights[k] * newAuthorities[outLinks[k]]
+					} else {
+						value += newAuthorities[outLinks[k]]
+					}
+				}
+				newHubs[i] = value
+				total.add(value)
+			}
+			return partialSums{total: total.value()}
+		}).total
+		sums := pool.each(func(lo, hi int) partialSums {
+			var distance accumulator
+			for i := lo; i < hi; i++ {
+				if authorityTotal != 0 {
+					newAuthorities[i] /= authorityTotal
+				}
+				if hubTotal != 0 {
+					newHubs[i] /= hubTotal
+				}
+				opts.Norm.add(&distance, math.Abs(newAuthorities[i]-authorities[i]))
+				opts.Norm.add(&distance, math.Abs(newHubs[i]-hubs[i]))
+			}
+			return partialSums{distance: distance.value()}
+		})
+		hubs, newHubs = newHubs, hubs
+		authorities, newAuthorities = newAuthorities, authorities
+		residual := opts.Norm.finish(sums.distance)
+		result.Iterations++
+		result.History = append(result.History, residual)
+		if opts.converged(residual) || opts.reachedMax(result.Iterations) {
+			break
+		}
+	}
+	result.Hubs, result.Authorities = hubs, authorities
+	return result, opts.convergenceErr(result.History)
+}
+
+// MatchURLs returns the URLs that contain any of the comma separated
+// terms of query, ignoring case. They make up the root set of a query
+// focused HITS run, see BaseSet.
+func MatchURLs(urls []string, query string) []string {
+	terms := []string{}
+	for _, term := range strings.Split(query, ",") {
+		if term = strings.ToLower(strings.TrimSpace(term)); term != "" {
+			terms = append(terms, term)
+		}
+	}
+	matches := []string{}
+	for _, url := range urls {
+		lower := strings.ToLower(url)
+		for _, term := range terms {
+			if strings.Contains(lower, term) {
+				matches = append(matches, url)
+				break
+			}
+		}
+	}
+	return matches
+}
+
+// BaseSet returns the query focused subgraph HITS was designed for: the
+// root URLs, every node they link to, and up to maxIn of the nodes that
+// link to each root, or all of them when maxIn is below one. Root URLs
+// that are not part of c are ignored. The subgraph keeps the links
+// between its nodes with their weights and numbers them in the order of
+// c.
+func BaseSet(c *CSR, root []string, maxIn int) *CSR {
+	n := c.NumNodes()
+	outOffsets, outLinks := outAdjacency(c)
+	member := make([]bool, n)
+	for _, url := range root {
+		id, ok := c.ID(url)
+		if !ok {
+			continue
+		}
+		member[id] = true
+		for _, j := range outLinks[outOffsets[id]:outOffsets[id+1]] {
+			member[j] = true
+		}
+		in := c.In(id)
+		if maxIn > 0 && len(in) > maxIn {
+			in = in[:maxIn]
+		}
+		for _, j := range in {
+			member[j] = true
+		}
+	}
+	b := newCSRBuilder()
+	for id := 0; id < n; id++ {
+		if member[id] {
+			b.intern(c.URLs[id])
+		}
+	}
+	for id := 0; id < n; id++ {
+		if !member[id] {
+			continue
+		}
+		for k := c.InOffsets[id]; k < c.InOffsets[id+1]; k++ {
+			j := c.InLinks[k]
+			if !member[j] {
+				continue
+			}
+			if c.Weights != nil {
+				b.addWeightedEdge(c.URLs[j], c.URLs[id], c.Weights[k])
+			} else {
+				b.addEdge(c.URLs[j], c.URLs[id])
+			}
+		}
+	}
+	return b.build()
+}
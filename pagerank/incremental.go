@@ -0,0 +1,453 @@
+package pagerank
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"../canonical"
+)
+
+// Delta is a change to a link graph, as found between two crawls
+type Delta struct {
+	AddedNodes   []string
+	RemovedNodes []string
+	AddedEdges   []Edge
+	RemovedEdges []Edge
+}
+
+// Empty reports whether the delta changes nothing
+func (delta Delta) Empty() bool {
+	return len(delta.AddedNodes)+len(delta.RemovedNodes)+len(delta.AddedEdges)+len(delta.RemovedEdges) == 0
+}
+
+// RemoveEdge removes one link from src to dest and reports whether
+// there was one
+func (g *Graph) RemoveEdge(src, dest string) bool {
+	in := g.AdjacencyList[dest]
+	for idx, from := range in {
+		if from != src {
+			continue
+		}
+		g.AdjacencyList[dest] = append(in[:idx], in[idx+1:]...)
+		g.OutLinks[src]--
+		remaining := false
+		for _, from := range g.AdjacencyList[dest] {
+			remaining = remaining || from == src
+		}
+		if !remaining {
+			delete(g.EdgeAttrs, Edge{src, dest})
+		}
+		return true
+	}
+	return false
+}
+
+// RemoveNode removes url together with all of its links
+func (g *Graph) RemoveNode(url string) {
+	if !g.visited[url] {
+		return
+	}
+	for _, src := range g.AdjacencyList[url] {
+		g.OutLinks[src]--
+		delete(g.EdgeAttrs, Edge{src, url})
+	}
+	delete(g.AdjacencyList, url)
+	for dest, in := range g.AdjacencyList {
+		kept := in[:0]
+		for _, src := range in {
+			if src == url {
+				delete(g.EdgeAttrs, Edge{url, dest})
+			} else {
+				kept = append(kept, src)
+			}
+		}
+		g.AdjacencyList[dest] = kept
+	}
+	delete(g.OutLinks, url)
+	delete(g.NodeAttrs, url)
+	delete(g.visited, url)
+	for idx, node := range g.Nodes {
+		if node == url {
+			g.Nodes = append(g.Nodes[:idx], g.Nodes[idx+1:]...)
+			break
+		}
+	}
+}
+
+// Apply changes g by delta: links and nodes are removed first, then
+// nodes and links are added
+func (g *Graph) Apply(delta Delta) {
+	for _, e := range delta.RemovedEdges {
+		g.RemoveEdge(e.Src, e.Dest)
+	}
+	for _, url := range delta.RemovedNodes {
+		g.RemoveNode(url)
+	}
+	for _, url := range delta.AddedNodes {
+		g.AddNode(url)
+	}
+	for _, e := range delta.AddedEdges {
+		g.AddEdge(e.Src, e.Dest)
+	}
+}
+
+// Diff returns the delta that turns old into new. Parallel links are
+// counted, so adding a second link between two nodes is a change.
+func Diff(old, new *Graph) Delta {
+	var delta Delta
+	for _, url := range new.Nodes {
+		if !old.HasNode(url) {
+			delta.AddedNodes = append(delta.AddedNodes, url)
+		}
+	}
+	for _, url := range old.Nodes {
+		if !new.HasNode(url) {
+			delta.RemovedNodes = append(delta.RemovedNodes, url)
+		}
+	}
+	edges := func(g *Graph) map[Edge]int {
+		count := make(map[Edge]int)
+		for dest, in := range g.AdjacencyList {
+			for _, src := range in {
+				count[Edge{src, dest}]++
+			}
+		}
+		return count
+	}
+	oldEdges, newEdges := edges(old), edges(new)
+	for e, n := range newEdges {
+		for i := oldEdges[e]; i < n; i++ {
+			delta.AddedEdges = append(delta.AddedEdges, e)
+		}
+	}
+	for e, n := range oldEdges {
+		// Links of removed nodes go away with the node
+		if !new.HasNode(e.Src) || !new.HasNode(e.Dest) {
+			continue
+		}
+		for i := newEdges[e]; i < n; i++ {
+			delta.RemovedEdges = append(delta.RemovedEdges, e)
+		}
+	}
+	sortEdges(delta.AddedEdges)
+	sortEdges(delta.RemovedEdges)
+	return delta
+}
+
+func sortEdges(edges []Edge) {
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].Src != edges[j].Src {
+			return edges[i].Src < edges[j].Src
+		}
+		return edges[i].Dest < edges[j].Dest
+	})
+}
+
+// ReadDelta reads a delta file. Every line is "+ src dest" or "- src dest"
+// to add or remove a link, or "+ url" or "- url" to add or remove a
+// node; blank lines and lines starting with # are skipped. The URLs are
+// rewritten by rules like those of the graph the delta applies to, see
+// ScanFile.
+func ReadDelta(path string, rules canonical.Rules) (Delta, error) {
+	var delta Delta
+	file, err := os.Open(path)
+	if err != nil {
+		return delta, err
+	}
+	defer file.Close()
+	err = scanLines(bufio.NewReader(file), "#", func(line int, fields []string) error {
+		if (fields[0] != "+" && fields[0] != "-") || len(fields) < 2 || len(fields) > 3 {
+			return &SyntaxError{Path: path, Line: line, Msg: "expected \"+|- src [dest]\""}
+		}
+		add := fields[0] == "+"
+		for idx := 1; idx < len(fields); idx++ {
+			fields[idx] = rules.URL(fields[idx])
+		}
+		switch {
+		case len(fields) == 2 && add:
+			delta.AddedNodes = append(delta.AddedNodes, fields[1])
+		case len(fields) == 2:
+			delta.RemovedNodes = append(delta.RemovedNodes, fields[1])
+		case add:
+			delta.AddedEdges = append(delta.AddedEdges, Edge{fields[1], fields[2]})
+		default:
+			delta.RemovedEdges = append(delta.RemovedEdges, Edge{fields[1], fields[2]})
+		}
+		return nil
+	})
+	return delta, err
+}
+
+// UpdateResult holds the outcome of Update
+type UpdateResult struct {
+	// Updated page rank values, indexed by node ID
+	Ranks []float64
+	// Number of times a node passed its residual on to its outlinks
+	Pushes int
+	// Number of nodes whose value changed
+	Touched int
+	// Set when the update fell back to a full computation started from
+	// the previous ranking, which took Iterations iterations
+	Recomputed bool
+	Iterations int
+}
+
+// Update brings the previous ranking up to date with the graph c, which
+// is the graph previous was computed on changed by delta.
+//
+// When the rank of dangling nodes follows the teleport distribution, as
+// with DanglingPersonalized or DanglingUniform without personalization,
+// the ranking is the normalized solution of
+//
+//	y(i) = (1-d)*t(i) + d*SUM{j->i}[y(j)/|Oj|]
+//
+// where dangling nodes keep their rank and t is the unnormalized
+// personalization, or one for every node. The equation of a node only
+// involves its in-links, so the delta only changes the equations of the
+// nodes it adds, the destinations of removed links and the outlinks of
+// every source whose links changed. Update starts from the previous
+// values, scaled to solve the old system, and computes the residual
+//
+//	r(i) = (1-d)*t(i) + d*SUM{j->i}[y(j)/|Oj|] - y(i)
+//
+// of those nodes only. A node whose residual is too large absorbs it
+// into its value and passes d/|Oi| of it on to each of its outlinks, so
+// only the region around the change is visited. Pushing stops once the
+// L1 norm of the residual bounds the error of the normalized values by
+// opts.Epsilon, so the result matches ComputeCSR within the tolerance.
+//
+// Removed nodes take their unknown outlinks with them, so then the
+// residual of every node is computed once before pushing. In the other
+// dangling modes the ranking is not local, and Update falls back to
+// ComputeCSR started from the previous values; so it does when
+// Options.Links merges links.
+func Update(c *CSR, previous Ranks, delta Delta, opts Options) (*UpdateResult, error) {
+	n := c.NumNodes()
+	teleport := teleportWeights(c, opts)
+	weights := danglingWeights(c, opts, teleport)
+	if !sameValues(weights, teleport) || opts.Links != LinksMultiple {
+		opts.Start = previous
+		full, err := ComputeCSR(c, opts)
+		if full == nil {
+			return nil, err
+		}
+		return &UpdateResult{Ranks: full.Ranks, Recomputed: true, Iterations: full.Iterations}, err
+	}
+	result := &UpdateResult{Ranks: make([]float64, n)}
+	if n == 0 {
+		return result, nil
+	}
+	d := opts.Damping
+	raw := c.Vector(opts.Personalization)
+	if sumOf(raw) == 0 {
+		for i := range raw {
+			raw[i] = 1
+		}
+	}
+
+	// Nodes that are new, or whose equation changed
+	outOffsets, outLinks := outAdjacency(c)
+	affected, danglingChange := affectedNodes(c, outOffsets, outLinks, previous, delta)
+	y := result.Ranks
+	present, rawSum := 0, 0.0
+	for id, url := range c.URLs {
+		if value, ok := previous[url]; ok {
+			y[id] = value
+			rawSum += raw[id]
+			present++
+		}
+	}
+	local := present == len(previous)
+	if !local {
+		affected = affected[:0]
+		for id := range y {
+			affected = append(affected, uint32(id))
+		}
+	}
+	// The previous values sum to one. Solving the system above instead
+	// scales them by (1-d)*SUM{t} / ((1-d) + d*SUM{D}[p(j)]), with the
+	// dangling nodes of the old graph.
+	oldDangling := danglingRank(c, y, 0, n) - danglingChange
+	scale := (1 - d) * rawSum / ((1 - d) + d*oldDangling)
+	for id, url := range c.URLs {
+		if _, ok := previous[url]; ok {
+			y[id] *= scale
+		} else {
+			// New nodes start with their random clicks
+			y[id] = (1 - d) * raw[id]
+		}
+	}
+
+	r := make([]float64, n)
+	for _, i := range affected {
+		prestige := 0.0
+		for _, j := range c.In(i) {
+			prestige += y[j] / float64(c.OutDegree[j])
+		}
+		r[i] = (1-d)*raw[i] + d*prestige - y[i]
+	}
+	// The L1 error of y is at most the L1 norm of r over 1-d, and
+	// normalizing at most doubles its share of SUM{y}
+	threshold := opts.Epsilon * (1 - d) * sumOf(y) / float64(2*n)
+	queued := make([]bool, n)
+	touched := make([]bool, n)
+	queue := []uint32{}
+	enqueue := func(i uint32) {
+		if !queued[i] && math.Abs(r[i]) > threshold {
+			queued[i] = true
+			queue = append(queue, i)
+		}
+	}
+	for _, i := range affected {
+		enqueue(i)
+	}
+	maxPushes := opts.MaxIterations * n
+	for len(queue) > 0 && (maxPushes == 0 || result.Pushes < maxPushes) {
+		i := queue[0]
+		queue = queue[1:]
+		queued[i] = false
+		push := r[i]
+		y[i] += push
+		r[i] = 0
+		touched[i] = true
+		result.Pushes++
+		if c.OutDegree[i] == 0 {
+			continue
+		}
+		share := d * push / float64(c.OutDegree[i])
+		for _, k := range outLinks[outOffsets[i]:outOffsets[i+1]] {
+			r[k] += share
+			enqueue(k)
+		}
+	}
+	for _, t := range touched {
+		if t {
+			result.Touched++
+		}
+	}
+	total := sumOf(y)
+	normalize(y)
+	if len(queue) > 0 {
+		residual := 2 * sumOf(absolute(r)) / ((1 - d) * total)
+		return result, &ConvergenceError{opts.MaxIterations, residual, opts.Epsilon, NormL1}
+	}
+	return result, nil
+}
+
+// Reports whether x and y hold the same values
+func sameValues(x, y []float64) bool {
+	if x == nil || len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func absolute(v []float64) []float64 {
+	abs := make([]float64, len(v))
+	for i, value := range v {
+		abs[i] = math.Abs(value)
+	}
+	return abs
+}
+
+// Returns the nodes of c that are missing from previous or whose equation
+// delta changed, given the outlinks of c, and how much more of the
+// previous rank the dangling nodes of c hold than those of the old graph
+func affectedNodes(c *CSR, outOffsets, outLinks []uint32, previous Ranks, delta Delta) (affected []uint32, dangling float64) {
+	seen := make([]bool, c.NumNodes())
+	add := func(id uint32) {
+		if !seen[id] {
+			seen[id] = true
+			affected = append(affected, id)
+		}
+	}
+	for id, url := range c.URLs {
+		if _, ok := previous[url]; !ok {
+			add(uint32(id))
+		}
+	}
+	// Net change of the outlinks of every source
+	changed := make(map[string]int)
+	for _, e := range delta.AddedEdges {
+		changed[e.Src]++
+	}
+	for _, e := range delta.RemovedEdges {
+		changed[e.Src]--
+		if id, ok := c.ID(e.Dest); ok {
+			add(id)
+		}
+	}
+	sources := make([]string, 0, len(changed))
+	for src := range changed {
+		sources = append(sources, src)
+	}
+	sort.Strings(sources)
+	for _, src := range sources {
+		id, ok := c.ID(src)
+		if !ok {
+			continue
+		}
+		// Every outlink of a source now gets a different share of it
+		for _, k := range outLinks[outOffsets[id]:outOffsets[id+1]] {
+			add(k)
+		}
+		out := int(c.OutDegree[id])
+		before := out - changed[src]
+		if before == 0 && out > 0 {
+			dangling -= previous[src]
+		} else if before > 0 && out == 0 {
+			dangling += previous[src]
+		}
+	}
+	return affected, dangling
+}
+
+// Returns the outlinks of every node of c in compressed sparse row form:
+// the outlinks of node i are links[offsets[i]:offsets[i+1]]. Outlinks
+// that point outside of a subgraph are left out.
+func outAdjacency(c *CSR) (offsets, links []uint32) {
+	n := c.NumNodes()
+	offsets = make([]uint32, n+1)
+	for _, j := range c.InLinks {
+		offsets[j+1]++
+	}
+	for i := 0; i < n; i++ {
+		offsets[i+1] += offsets[i]
+	}
+	links = make([]uint32, c.NumEdges())
+	next := append([]uint32(nil), offsets[:n]...)
+	for i := 0; i < n; i++ {
+		for _, j := range c.In(uint32(i)) {
+			links[next[j]] = uint32(i)
+			next[j]++
+		}
+	}
+	return offsets, links
+}
+
+// String returns the delta in the format read by ReadDelta
+func (delta Delta) String() string {
+	var b strings.Builder
+	for _, url := range delta.RemovedNodes {
+		fmt.Fprintf(&b, "- %s\n", url)
+	}
+	for _, e := range delta.RemovedEdges {
+		fmt.Fprintf(&b, "- %s %s\n", e.Src, e.Dest)
+	}
+	for _, url := range delta.AddedNodes {
+		fmt.Fprintf(&b, "+ %s\n", url)
+	}
+	for _, e := range delta.AddedEdges {
+		fmt.Fprintf(&b, "+ %s %s\n", e.Src, e.Dest)
+	}
+	return b.String()
+}
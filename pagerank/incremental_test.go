@@ -0,0 +1,129 @@
+package pagerank
+
+import (
+	"reflect"
+	"testing"
+
+	"../canonical"
+)
+
+func TestUpdateMatchesRecompute(t *testing.T) {
+	const a, b, c = "https://a.example.edu", "https://b.example.edu", "https://c.example.edu"
+	for _, tc := range []struct {
+		name      string
+		delta     Delta
+		links     Links
+		recompute bool
+	}{
+		{
+			name: "links added and removed",
+			delta: Delta{
+				AddedEdges:   []Edge{{c + "/dead", a}, {b + "/z", c + "/y"}, {a, b}},
+				RemovedEdges: []Edge{{a + "/x", c + "/y"}, {b, a}},
+			},
+		},
+		{
+			name: "nodes added and removed",
+			delta: Delta{
+				AddedNodes:   []string{"https://d.example.edu"},
+				RemovedNodes: []string{b + "/z"},
+				AddedEdges:   []Edge{{"https://d.example.edu", a}, {c, "https://d.example.edu"}},
+			},
+		},
+		{
+			name: "weighted links fall back",
+			delta: Delta{
+				AddedEdges:   []Edge{{a, b}, {a, b}, {c, a + "/x"}},
+				RemovedEdges: []Edge{{c, c + "/y"}},
+			},
+			links:     LinksWeighted,
+			recompute: true,
+		},
+	} {
+		graph, err := ReadGraph(writeGraph(t, "crawl.gv", testDot), "", canonical.Default)
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts := DefaultOptions()
+		opts.Epsilon = 1e-10
+		opts.Links = tc.links
+		before := NewCSR(graph)
+		previous, err := ComputeCSR(before, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		graph.Apply(tc.delta)
+		after := NewCSR(graph)
+		updated, err := Update(after, before.Ranks(previous.Ranks), tc.delta, opts)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if updated.Recomputed != tc.recompute {
+			t.Errorf("%s: recomputed = %v, want %v", tc.name, updated.Recomputed, tc.recompute)
+		}
+		want, err := ComputeCSR(after, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gap := NormL1.Residual(updated.Ranks, want.Ranks); gap > 10*opts.Epsilon {
+			t.Errorf("%s: L1 gap to a full computation is %g, above %g", tc.name, gap, 10*opts.Epsilon)
+		}
+	}
+}
+
+func TestReadDeltaCanonical(t *testing.T) {
+	path := writeGraph(t, "changes.txt", `# the same changes as spelled by another crawl
+- http://A.example.edu/x/ https://c.example.edu/y#top
++ https://d.example.edu:443/
++ http://c.example.edu/dead/ https://a.example.edu/
+- https://b.example.edu/z/
+`)
+	delta, err := ReadDelta(path, canonical.Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const a, c, d = "https://a.example.edu", "https://c.example.edu", "https://d.example.edu"
+	want := Delta{
+		AddedNodes:   []string{d},
+		RemovedNodes: []string{"https://b.example.edu/z"},
+		AddedEdges:   []Edge{{c + "/dead", a}},
+		RemovedEdges: []Edge{{a + "/x", c + "/y"}},
+	}
+	if !reflect.DeepEqual(delta, want) {
+		t.Fatalf("read %+v, want %+v", delta, want)
+	}
+
+	// The delta changes the graph it was spelled against
+	graph, err := ReadGraph(writeGraph(t, "crawl.gv", testDot), "", canonical.Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := DefaultOptions()
+	opts.Epsilon = 1e-10
+	before := NewCSR(graph)
+	previous, err := ComputeCSR(before, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes := len(graph.Nodes)
+	graph.Apply(delta)
+	if len(graph.Nodes) != nodes {
+		t.Errorf("%d nodes after adding and removing one, want %d", len(graph.Nodes), nodes)
+	}
+	if graph.OutLinks[a+"/x"] != 1 || graph.OutLinks[c+"/dead"] != 1 {
+		t.Errorf("links of /x and /dead were not changed: %v", graph.OutLinks)
+	}
+	after := NewCSR(graph)
+	updated, err := Update(after, before.Ranks(previous.Ranks), delta, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	full, err := ComputeCSR(after, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gap := NormL1.Residual(updated.Ranks, full.Ranks); gap > 10*opts.Epsilon {
+		t.Errorf("L1 gap to a full computation is %g, above %g", gap, 10*opts.Epsilon)
+	}
+}
@@ -0,0 +1,152 @@
+package pagerank
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Links selects how parallel links between the same two pages and the
+// weight attributes of links enter the ranking
+type Links int
+
+const (
+	// LinksMultiple counts every parallel link, so a page that links to
+	// the same target five times hands it five shares of its rank.
+	// Weight attributes are ignored. This is how the programs have
+	// always ranked, as the crawler writes one link per href.
+	LinksMultiple Links = iota
+	// LinksUnique collapses parallel links into one and ignores weight
+	// attributes
+	LinksUnique
+	// LinksWeighted collapses parallel links into one link whose weight
+	// is the sum of their weight attributes, one for links without, and
+	// distributes the rank of a page in proportion to the weights
+	LinksWeighted
+)
+
+var linksNames = []string{"multiple", "unique", "weighted"}
+
+func (l Links) String() string {
+	if l < 0 || int(l) >= len(linksNames) {
+		return fmt.Sprintf("Links(%d)", int(l))
+	}
+	return linksNames[l]
+}
+
+// ParseLinks returns the link mode called name: multiple, unique or
+// weighted
+func ParseLinks(name string) (Links, error) {
+	for l, linksName := range linksNames {
+		if name == linksName {
+			return Links(l), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown link mode %q, want one of %s", name, strings.Join(linksNames, ", "))
+}
+
+// Returns the weight attribute of a link and whether it has a valid one
+func edgeWeight(attrs Attrs) (float64, bool) {
+	value, ok := attrs["weight"]
+	if !ok {
+		return 0, false
+	}
+	w, err := strconv.ParseFloat(value, 64)
+	return w, err == nil
+}
+
+// Collapse returns c with its links merged according to mode. Node IDs
+// stay the same, so rank vectors of either graph fit the other.
+// LinksMultiple returns c itself. The other modes keep one link per pair
+// of pages, in the order of its first occurrence, and lower OutDegree
+// accordingly. LinksWeighted also sets Weights to the summed weight of
+// every merged link and OutWeights to the total weight of each node;
+// links without a positive weight are dropped, as they carry no rank.
+//
+// A graph built by NewCSR only knows one set of attributes per pair of
+// pages, so there every parallel link has the weight of the last one.
+func (c *CSR) Collapse(mode Links) *CSR {
+	if mode == LinksMultiple {
+		return c
+	}
+	n := c.NumNodes()
+	weighted := mode == LinksWeighted
+	out := &CSR{
+		URLs:      c.URLs,
+		IDs:       c.IDs,
+		InOffsets: make([]uint32, n+1),
+		InLinks:   make([]uint32, 0, len(c.InLinks)),
+		OutDegree: append([]uint32(nil), c.OutDegree...),
+	}
+	if weighted {
+		out.Weights = make([]float64, 0, len(c.InLinks))
+		out.OutWeights = make([]float64, n)
+	}
+	// Position of each source in the links of the current destination
+	seen := make(map[uint32]int)
+	for i := 0; i < n; i++ {
+		first := len(out.InLinks)
+		clear(seen)
+		for k := c.InOffsets[i]; k < c.InOffsets[i+1]; k++ {
+			j := c.InLinks[k]
+			w := 1.0
+			if c.Weights != nil {
+				w = c.Weights[k]
+			}
+			if pos, ok := seen[j]; ok {
+				out.OutDegree[j]--
+				if weighted {
+					out.Weights[pos] += w
+				}
+				continue
+			}
+			seen[j] = len(out.InLinks)
+			out.InLinks = append(out.InLinks, j)
+			if weighted {
+				out.Weights = append(out.Weights, w)
+			}
+		}
+		if weighted {
+			// Drop the links that ended up without weight
+			kept := first
+			for k := first; k < len(out.InLinks); k++ {
+				j := out.InLinks[k]
+				if out.Weights[k] <= 0 {
+					out.OutDegree[j]--
+					continue
+				}
+				out.InLinks[kept], out.Weights[kept] = j, out.Weights[k]
+				out.OutWeights[j] += out.Weights[k]
+				kept++
+			}
+			out.InLinks, out.Weights = out.InLinks[:kept], out.Weights[:kept]
+		}
+		out.InOffsets[i+1] = uint32(len(out.InLinks))
+	}
+	if weighted {
+		// Outlinks that leave a subgraph weigh one each
+		inside := make([]uint32, n)
+		for _, j := range out.InLinks {
+			inside[j]++
+		}
+		for j := range out.OutWeights {
+			out.OutWeights[j] += float64(out.OutDegree[j] - inside[j])
+		}
+	}
+	return out
+}
+
+// Returns the weights of the outlinks returned by outAdjacency, in the
+// same order, or nil when c is not weighted
+func outWeights(c *CSR, offsets []uint32) []float64 {
+	if c.OutWeights == nil {
+		return nil
+	}
+	weights := make([]float64, len(c.InLinks))
+	next := append([]uint32(nil), offsets[:c.NumNodes()]...)
+	for k, j := range c.InLinks {
+		weights[next[j]] = c.Weights[k]
+		next[j]++
+	}
+	return weights
+}
@@ -0,0 +1,146 @@
+package pagerank
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sort"
+	"sync/atomic"
+)
+
+// WalkResult holds the outcome of RandomWalks
+type WalkResult struct {
+	// Estimated page rank values, indexed by node ID
+	Ranks []float64
+	// Number of walks launched
+	Walks int
+	// Number of node visits over all walks
+	Steps uint64
+}
+
+// RandomWalks estimates the page rank of c by simulating the random
+// surfer instead of iterating the equation of ComputeCSR. Every node
+// launches walks random walks, in proportion to its share of the
+// teleport distribution when Options.Personalization is set. A walk
+// follows a random outlink with probability Options.Damping and ends
+// otherwise, and a walk that reaches a dangling node jumps to a node
+// drawn from the Options.Dangling distribution instead. With
+// DanglingRescale the walk ends there, which drops the rank of dangling
+// nodes much like the iteration does. With LinksWeighted the walk picks
+// an outlink in proportion to its weight. The estimate of a node is its
+// share of all visits; its error shrinks with the square root of walks.
+//
+// The walks of every block of nodes draw from their own generator seeded
+// with seed and the block number, and the visits are counted atomically,
+// so the estimate only depends on seed and walks, never on
+// Options.Workers. Options.Epsilon and the solver settings are ignored.
+func RandomWalks(c *CSR, opts Options, walks int, seed int64) (*WalkResult, error) {
+	if walks < 1 {
+		return nil, fmt.Errorf("need at least one walk per node, found %d", walks)
+	}
+	if opts.Damping < 0 || opts.Damping >= 1 {
+		return nil, fmt.Errorf("damping factor %g must be in [0, 1) for walks to end", opts.Damping)
+	}
+	c = c.Collapse(opts.Links)
+	n := c.NumNodes()
+	result := &WalkResult{Ranks: make([]float64, n)}
+	if n == 0 {
+		return result, nil
+	}
+	teleport := teleportWeights(c, opts)
+	// Walks per node, R for every node unless personalized
+	starts := make([]int, n)
+	seeds := 0
+	for _, t := range teleport {
+		if t > 0 {
+			seeds++
+		}
+	}
+	for i, t := range teleport {
+		if t > 0 {
+			starts[i] = max(1, int(math.Round(float64(walks)*float64(seeds)*t)))
+			result.Walks += starts[i]
+		}
+	}
+	jump := danglingJump(n, opts, teleport)
+	outOffsets, outLinks := outAdjacency(c)
+	linkWeights := outWeights(c, outOffsets)
+
+	visits := make([]uint64, n)
+	d := opts.Damping
+	pool := newNodePool(n, opts.Workers, opts.Norm)
+	pool.each(func(lo, hi int) partialSums {
+		rng := rand.New(rand.NewPCG(uint64(seed), uint64(lo/blockSize)))
+		for i := lo; i < hi; i++ {
+			for w := 0; w < starts[i]; w++ {
+				node := uint32(i)
+				for {
+					atomic.AddUint64(&visits[node], 1)
+					if rng.Float64() >= d {
+						// A random click ends the walk
+						break
+					}
+					if c.OutDegree[node] == 0 {
+						if jump == nil {
+							break
+						}
+						node = jump(rng)
+						continue
+					}
+					// Outlinks leaving a subgraph end the walk
+					lo, hi := outOffsets[node], outOffsets[node+1]
+					next := hi
+					if linkWeights == nil {
+						next = lo + uint32(rng.IntN(int(c.OutDegree[node])))
+					} else {
+						r := rng.Float64() * c.OutWeights[node]
+						for k := lo; k < hi; k++ {
+							if r -= linkWeights[k]; r < 0 {
+								next = k
+								break
+							}
+						}
+					}
+					if next >= hi {
+						break
+					}
+					node = outLinks[next]
+				}
+			}
+		}
+		return partialSums{}
+	})
+
+	for _, v := range visits {
+		result.Steps += v
+	}
+	for i, v := range visits {
+		result.Ranks[i] = float64(v) / float64(result.Steps)
+	}
+	return result, nil
+}
+
+// Returns a function drawing the node a walk jumps to from a dangling
+// node, or nil when the walk ends there
+func danglingJump(n int, opts Options, teleport []float64) func(*rand.Rand) uint32 {
+	switch opts.Dangling {
+	case DanglingRescale:
+		return nil
+	case DanglingPersonalized:
+		// Search the cumulative teleport distribution
+		cumulative := make([]float64, n)
+		var acc accumulator
+		for i, t := range teleport {
+			acc.add(t)
+			cumulative[i] = acc.value()
+		}
+		return func(rng *rand.Rand) uint32 {
+			r := rng.Float64() * cumulative[n-1]
+			i := sort.Search(n, func(i int) bool { return cumulative[i] > r })
+			return uint32(min(i, n-1))
+		}
+	}
+	return func(rng *rand.Rand) uint32 {
+		return uint32(rng.IntN(n))
+	}
+}
@@ -0,0 +1,320 @@
+package pagerank
+
+import (
+	"math"
+	"sort"
+)
+
+// Ranks maps a URL to its page rank value
+type Ranks map[string]float64
+
+// Pair is a URL together with its page rank value
+type Pair struct {
+	URL      string
+	PageRank float64
+}
+
+// Top returns the num nodes with the highest page rank scores, best
+// first. Ties are broken by URL so the order is always the same.
+func (r Ranks) Top(num int) []Pair {
+	tupleList := []Pair{}
+	for k, v := range r {
+		tupleList = append(tupleList, Pair{k, v})
+	}
+	sort.Slice(tupleList, func(i, j int) bool {
+		if tupleList[i].PageRank != tupleList[j].PageRank {
+			return tupleList[i].PageRank > tupleList[j].PageRank
+		}
+		return tupleList[i].URL < tupleList[j].URL
+	})
+	if num < len(tupleList) {
+		tupleList = tupleList[:num]
+	}
+	return tupleList
+}
+
+// Dangling selects what happens to the page rank of dangling nodes,
+// the nodes without any outlinks
+type Dangling int
+
+const (
+	// DanglingUniform spreads the rank of dangling nodes evenly over
+	// every node, as if a dangling node linked to the whole graph
+	DanglingUniform Dangling = iota
+	// DanglingPersonalized spreads the rank of dangling nodes in
+	// proportion to Options.Personalization
+	DanglingPersonalized
+	// DanglingRescale drops the rank of dangling nodes and rescales the
+	// values to sum to one after each iteration. This is how the
+	// programs behaved before dangling nodes were handled explicitly.
+	// Only SolverJacobi supports it.
+	DanglingRescale
+)
+
+// Options controls the page rank computation
+type Options struct {
+	// Probability that weights influence of Random Click and Prestige
+	Damping float64
+	// The computation stops once the residual between two iterations,
+	// measured in Norm, falls below Epsilon
+	Epsilon float64
+	// Norm of the residual, L1 by default
+	Norm Norm
+	// The computation also stops after MaxIterations iterations, unless
+	// it is zero, and returns a *ConvergenceError if the residual is
+	// still above Epsilon
+	MaxIterations int
+	// Starting page rank values. When nil every node starts at 1/|V|,
+	// otherwise the values are normalized to sum to one and nodes
+	// without a value start at zero.
+	Start Ranks
+	// What to do with the page rank of dangling nodes
+	Dangling Dangling
+	// Personalization vector. A random click teleports to node i with
+	// probability Personalization[i], which biases the ranking toward
+	// those nodes; DanglingPersonalized uses it as well. The values are
+	// normalized to sum to one; when nil or all zero the uniform
+	// distribution 1/|V| is used instead.
+	Personalization Ranks
+	// Number of goroutines ComputeCSR splits every iteration over. Zero
+	// uses GOMAXPROCS and one runs on the calling goroutine; the values
+	// are the same either way.
+	Workers int
+	// Iteration used by ComputeCSR, the power iteration by default. The
+	// other solvers return an error with DanglingRescale.
+	Solver Solver
+	// Relaxation factor of SolverSOR, usually between 1 and 2. Zero
+	// means 1, which is plain Gauss-Seidel.
+	Omega float64
+	// Keep the rank vectors of ComputeCSR in float32 instead of float64,
+	// which halves their memory at the cost of precision: the tolerance
+	// should stay well above the float32 rounding error of about 1e-7.
+	Float32 bool
+	// How ComputeCSR treats parallel links and link weights, see Links
+	Links Links
+}
+
+// DefaultMaxIterations caps the iterations of DefaultOptions. Power
+// iteration with d = 0.9 shrinks the error at least tenfold every 22
+// iterations, so the cap is only reached when the residual stalls above
+// the tolerance, for example through rounding on huge graphs.
+const DefaultMaxIterations = 1000
+
+// DefaultOptions returns the parameters both programs have always used,
+// with an iteration cap so a stalled residual cannot loop forever
+func DefaultOptions() Options {
+	return Options{Damping: 0.9, Epsilon: 0.0001, MaxIterations: DefaultMaxIterations}
+}
+
+// Reports whether iterations reached the iteration cap
+func (opts Options) reachedMax(iterations int) bool {
+	return opts.MaxIterations > 0 && iterations >= opts.MaxIterations
+}
+
+// Result holds the outcome of Compute
+type Result struct {
+	// Final page rank values
+	Ranks Ranks
+	// Number of iterations until the values converged
+	Iterations int
+	// Residual between consecutive iterations in Options.Norm, one per
+	// iteration
+	History []float64
+}
+
+// Normalize values in the vector to sum to one. Returns false, leaving
+// the vector untouched, when the values sum to zero.
+func normalize[F real](v []F) bool {
+	sum := sumOf(v)
+	if sum == 0 {
+		return false
+	}
+	for i := range v {
+		v[i] = F(float64(v[i]) / sum)
+	}
+	return true
+}
+
+// Fills v with the uniform distribution 1/|V|
+func uniform(v []float64) {
+	for i := range v {
+		v[i] = 1 / float64(len(v))
+	}
+}
+
+// Returns the teleport distribution of a random click, which is the
+// normalized personalization vector or the uniform distribution
+func teleportWeights(c *CSR, opts Options) []float64 {
+	w := c.Vector(opts.Personalization)
+	if !normalize(w) {
+		uniform(w)
+	}
+	return w
+}
+
+// Returns the distribution that receives the rank of dangling nodes,
+// or nil when that rank is dropped
+func danglingWeights(c *CSR, opts Options, teleport []float64) []float64 {
+	switch opts.Dangling {
+	case DanglingRescale:
+		return nil
+	case DanglingPersonalized:
+		return teleport
+	}
+	w := make([]float64, c.NumNodes())
+	uniform(w)
+	return w
+}
+
+// Compute runs page rank on g until the values stop changing. See
+// ComputeCSR for the equation; Compute converts g into compressed sparse
+// row form first and hands back the values keyed by URL.
+func Compute(g *Graph, opts Options) (*Result, error) {
+	c := NewCSR(g)
+	result, err := ComputeCSR(c, opts)
+	if result == nil {
+		return nil, err
+	}
+	return &Result{Ranks: c.Ranks(result.Ranks), Iterations: result.Iterations, History: result.History}, err
+}
+
+// ComputeCSR runs page rank on c until the values stop changing.
+//
+// The following equation is used to calculate the rank for a single node:
+//
+//	p(i) = (1-d)*t(i) + d*SUM{0...k}[(1/|Oj|) * p(j)] + d*w(i)*SUM{D}[p(j)]
+//	       Random Click + Prestige                      + Dangling
+//
+//	d = Probability that weights influence of Random Click and Prestige
+//	t(i) = Teleport probability of node i, 1/|V| unless personalized
+//	|V| = Number of nodes in the graph
+//	{0...k} = Nodes that have edges pointing to node i
+//	j = A node that has an edge pointing to node i
+//	|Oj| = Number of outlinks from node j.
+//	p(j) = The page rank for node j
+//	D = Dangling nodes, the nodes without outlinks
+//	w(i) = Share of the dangling rank node i receives, see Options.Dangling
+//
+// This equation states that the probability of visiting a node, i, is the sum of:
+//  1. A random click probability
+//  2. The prestige of node, i.
+//  3. The chance of jumping to node i after reaching a dead end
+//
+// The page rank values are kept in two slices indexed by node ID, which
+// are swapped after every iteration. Each iteration is a Jacobi step that
+// only reads the old values, so Options.Workers goroutines rank disjoint
+// blocks of nodes at the same time. Options.Solver swaps the Jacobi step
+// for in-place Gauss-Seidel or SOR sweeps. The slices hold float64 values
+// unless Options.Float32 is set; sums over all nodes are compensated
+// either way.
+//
+// Options.Links can merge parallel links first. In the weighted mode the
+// prestige term passes on rank in proportion to the weight of each link
+// instead of 1/|Oj|.
+//
+// When Options.MaxIterations runs out first, the values of the last
+// iteration are returned with a *ConvergenceError. Options the solver
+// cannot rank with return a nil result and an error.
+func ComputeCSR(c *CSR, opts Options) (*CSRResult, error) {
+	if err := opts.checkSolver(); err != nil {
+		return nil, err
+	}
+	c = c.Collapse(opts.Links)
+	n := c.NumNodes()
+	if n == 0 {
+		return &CSRResult{Ranks: []float64{}}, nil
+	}
+	start := make([]float64, n)
+	if opts.Start != nil {
+		start = c.Vector(opts.Start)
+	}
+	if opts.Start == nil || !normalize(start) {
+		uniform(start)
+	}
+	teleport := teleportWeights(c, opts)
+	weights := danglingWeights(c, opts, teleport)
+	if opts.Float32 {
+		return solve(c, opts, convert[float32](start), convert[float32](teleport), convert[float32](weights))
+	}
+	return solve(c, opts, start, teleport, weights)
+}
+
+// Runs the solver of opts on rank vectors of type F
+func solve[F real](c *CSR, opts Options, start, teleport, weights []F) (*CSRResult, error) {
+	if opts.Solver != SolverJacobi {
+		return gaussSeidel(c, opts, start, teleport, weights)
+	}
+	return jacobi(c, opts, start, teleport, weights)
+}
+
+// Runs the power iteration from the start vector, see ComputeCSR
+func jacobi[F real](c *CSR, opts Options, start, teleport, weights []F) (*CSRResult, error) {
+	n := c.NumNodes()
+	d := opts.Damping
+	pageRankOld := make([]F, n)
+	pageRankNew := start
+	pool := newNodePool(n, opts.Workers, opts.Norm)
+	// Rank held by dangling nodes in the previous iteration
+	danglingSum := pool.each(func(lo, hi int) partialSums {
+		return partialSums{dangling: danglingRank(c, pageRankNew, lo, hi)}
+	}).dangling
+	// Continue to calculate page rank until a minimum threshold is reached
+	// The threshold is a measure of the graph's change, so we quit when the
+	// the graph stops changing.
+	result := &CSRResult{}
+	for {
+		pageRankOld, pageRankNew = pageRankNew, pageRankOld
+		// Calculate page rank for each node
+		sums := pool.each(func(lo, hi int) partialSums {
+			var distance, total accumulator
+			for i := lo; i < hi; i++ {
+				prestige := 0.0
+				w := c.InWeights(uint32(i))
+				// Nodes that do not have any in-edges have a prestige of zero
+				for k, j := range c.In(uint32(i)) {
+					// Will never divide by zero since j points to i
+					prestige += c.flow(float64(pageRankOld[j]), j, w, k)
+				}
+				randomClick := (1 - d) * float64(teleport[i])
+				value := randomClick + d*prestige
+				if weights != nil {
+					value += d * danglingSum * float64(weights[i])
+				}
+				pageRankNew[i] = F(value)
+				total.add(float64(pageRankNew[i]))
+				opts.Norm.add(&distance, math.Abs(float64(pageRankOld[i])-float64(pageRankNew[i])))
+			}
+			return partialSums{distance.value(), danglingRank(c, pageRankNew, lo, hi), total.value()}
+		})
+		if weights == nil && sums.total != 0 {
+			// Normalize because we want the sum of probabilities to equal one
+			total := sums.total
+			sums = pool.each(func(lo, hi int) partialSums {
+				for i := lo; i < hi; i++ {
+					pageRankNew[i] = F(float64(pageRankNew[i]) / total)
+				}
+				return partialSums{distance: partialResidual(opts.Norm, pageRankOld[lo:hi], pageRankNew[lo:hi])}
+			})
+		}
+		danglingSum = sums.dangling
+		residual := opts.Norm.finish(sums.distance)
+		result.Iterations++
+		result.History = append(result.History, residual)
+		if opts.converged(residual) || opts.reachedMax(result.Iterations) {
+			break
+		}
+	}
+	result.Ranks = convert[float64](pageRankNew)
+	return result, opts.convergenceErr(result.History)
+}
+
+// Returns the rank v gives the dangling nodes in [lo, hi)
+func danglingRank[F real](c *CSR, v []F, lo, hi int) float64 {
+	var sum accumulator
+	for i := lo; i < hi; i++ {
+		if c.OutDegree[i] == 0 {
+			sum.add(float64(v[i]))
+		}
+	}
+	return sum.value()
+}
@@ -0,0 +1,92 @@
+package pagerank
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// Number of nodes in the blocks the workers of a nodePool claim. Small
+// enough to balance uneven in-degrees, large enough that claiming a block
+// costs little next to ranking it.
+const blockSize = 1024
+
+// Sums a worker accumulates over one block of nodes
+type partialSums struct {
+	// Partial residual between the old and new values of the block
+	distance float64
+	// New rank held by dangling nodes
+	dangling float64
+	// Sum of the new values
+	total float64
+}
+
+// A nodePool runs a function over the node range [0, n) on a bounded
+// number of goroutines. The range is cut into fixed blocks that workers
+// claim one at a time, and the partial sums are added up in block order
+// afterwards, so the floating point result is the same for any number of
+// workers.
+type nodePool struct {
+	n       int
+	workers int
+	norm    Norm
+	partial []partialSums
+}
+
+// Returns a pool over n nodes with the given number of workers, or
+// GOMAXPROCS workers when it is below one. The partial residuals are
+// merged in norm.
+func newNodePool(n, workers int, norm Norm) *nodePool {
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	blocks := (n + blockSize - 1) / blockSize
+	if workers > blocks {
+		workers = blocks
+	}
+	return &nodePool{n: n, workers: workers, norm: norm, partial: make([]partialSums, blocks)}
+}
+
+// Calls f for every block [lo, hi) and returns the compensated sum of
+// its results
+func (p *nodePool) each(f func(lo, hi int) partialSums) partialSums {
+	if p.workers <= 1 {
+		for b := range p.partial {
+			p.partial[b] = f(p.block(b))
+		}
+	} else {
+		var next int64 = -1
+		var wg sync.WaitGroup
+		for w := 0; w < p.workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					b := int(atomic.AddInt64(&next, 1))
+					if b >= len(p.partial) {
+						return
+					}
+					p.partial[b] = f(p.block(b))
+				}
+			}()
+		}
+		wg.Wait()
+	}
+	var distance, dangling, total accumulator
+	for _, s := range p.partial {
+		p.norm.merge(&distance, s.distance)
+		dangling.add(s.dangling)
+		total.add(s.total)
+	}
+	return partialSums{distance.value(), dangling.value(), total.value()}
+}
+
+// Returns the node range of block b
+func (p *nodePool) block(b int) (lo, hi int) {
+	lo = b * blockSize
+	hi = lo + blockSize
+	if hi > p.n {
+		hi = p.n
+	}
+	return lo, hi
+}
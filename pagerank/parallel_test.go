@@ -0,0 +1,65 @@
+package pagerank
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"runtime"
+	"testing"
+)
+
+// Builds a random graph of n nodes, spread over several node pool
+// blocks, where about one node in ten is dangling
+func randomCSR(n int, seed uint64) *CSR {
+	rng := rand.New(rand.NewPCG(seed, 0))
+	b := newCSRBuilder()
+	url := func(i int) string {
+		return fmt.Sprintf("https://d%d.example.edu/%d", i%17, i)
+	}
+	for i := 0; i < n; i++ {
+		b.intern(url(i))
+	}
+	for i := 0; i < n; i++ {
+		if rng.IntN(10) == 0 {
+			continue
+		}
+		for links := 1 + rng.IntN(8); links > 0; links-- {
+			b.addEdge(url(i), url(rng.IntN(n)))
+		}
+	}
+	return b.build()
+}
+
+func TestComputeCSRSameForAnyWorkers(t *testing.T) {
+	graph := randomCSR(5*blockSize+37, 1)
+	workers := []int{1, 2, 7, runtime.GOMAXPROCS(0)}
+	for _, solver := range []Solver{SolverJacobi, SolverGaussSeidel} {
+		var want *CSRResult
+		for _, w := range workers {
+			opts := DefaultOptions()
+			opts.Epsilon = 1e-10
+			opts.Solver = solver
+			opts.Workers = w
+			got, err := ComputeCSR(graph, opts)
+			if err != nil {
+				t.Fatalf("%s, %d workers: %v", solver, w, err)
+			}
+			if want == nil {
+				want = got
+				continue
+			}
+			if got.Iterations != want.Iterations || len(got.History) != len(want.History) {
+				t.Fatalf("%s, %d workers: %d iterations, want %d", solver, w, got.Iterations, want.Iterations)
+			}
+			for idx := range want.History {
+				if got.History[idx] != want.History[idx] {
+					t.Fatalf("%s, %d workers: residual %d is %v, want %v", solver, w, idx, got.History[idx], want.History[idx])
+				}
+			}
+			for id := range want.Ranks {
+				if got.Ranks[id] != want.Ranks[id] {
+					t.Fatalf("%s, %d workers: rank of node %d is %v, want %v", solver, w, id, got.Ranks[id], want.Ranks[id])
+				}
+			}
+		}
+	}
+}
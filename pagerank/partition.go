@@ -0,0 +1,334 @@
+package pagerank
+
+import (
+	"hash/fnv"
+	"math"
+	"sort"
+	"sync"
+
+	"../canonical"
+)
+
+// Partition is the part of a link graph owned by one worker of the
+// distributed computation. It holds the owned nodes together with every
+// link whose source is owned. Destinations owned by another partition
+// are kept as ghost nodes: their page rank is not computed here, but the
+// rank flowing into them is collected and sent to their owner.
+type Partition struct {
+	// Link graph over the owned nodes and the ghost nodes
+	Graph *CSR
+	// Reports whether a node ID is owned by this partition
+	Owned []bool
+	// Page rank values of the owned nodes, indexed by node ID
+	Ranks []float64
+	// Rank sent to the owned nodes by other partitions
+	incoming []float64
+	// Page rank values computed by the current superstep
+	next []float64
+	// Unnormalized personalization and start values of the owned nodes
+	personalization []float64
+	start           []float64
+	// Normalized teleport and dangling distributions, set by Init
+	teleport []float64
+	weights  []float64
+	// Norm of the residual returned by Finish, set by Init
+	norm Norm
+}
+
+// Owner maps each domain to one of workers partitions. Domains are
+// sorted and dealt out round robin; URLs whose domain is not listed are
+// hashed, so every process that builds the same Owner agrees on it.
+type Owner struct {
+	Domains map[string]int
+	Workers int
+}
+
+// NewOwner deals domains out over workers partitions
+func NewOwner(domains []string, workers int) Owner {
+	sorted := append([]string(nil), domains...)
+	sort.Strings(sorted)
+	o := Owner{Domains: make(map[string]int), Workers: workers}
+	for idx, domain := range sorted {
+		o.Domains[domain] = idx % workers
+	}
+	return o
+}
+
+// Of returns the partition that owns url
+func (o Owner) Of(url string) int {
+	domain, _ := Domain(url)
+	if idx, ok := o.Domains[domain]; ok {
+		return idx
+	}
+	h := fnv.New32a()
+	h.Write([]byte(domain))
+	return int(h.Sum32() % uint32(o.Workers))
+}
+
+// ReadPartition reads the graph at path in the given input format, with
+// the URLs rewritten by rules, and keeps the part of it that is owned by
+// partition self
+func ReadPartition(path, format string, rules canonical.Rules, owner Owner, self int) (*Partition, error) {
+	b := newCSRBuilder()
+	err := ScanFile(path, format, rules, Handler{
+		Node: func(url string, attrs Attrs) {
+			if owner.Of(url) == self {
+				b.intern(url)
+			}
+		},
+		Edge: func(src, dest string, attrs Attrs) {
+			if owner.Of(src) == self {
+				b.addEdge(src, dest)
+			} else if owner.Of(dest) == self {
+				// Nodes without owned in-links still need a page rank
+				b.intern(dest)
+			}
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	c := b.build()
+	p := &Partition{
+		Graph:    c,
+		Owned:    make([]bool, c.NumNodes()),
+		Ranks:    make([]float64, c.NumNodes()),
+		incoming: make([]float64, c.NumNodes()),
+		next:     make([]float64, c.NumNodes()),
+	}
+	for id, url := range c.URLs {
+		p.Owned[id] = owner.Of(url) == self
+	}
+	return p, nil
+}
+
+// Converts r into a vector that is zero for ghost nodes
+func (p *Partition) ownedVector(r Ranks) []float64 {
+	v := p.Graph.Vector(r)
+	for id := range v {
+		if !p.Owned[id] {
+			v[id] = 0
+		}
+	}
+	return v
+}
+
+// NumOwned returns the number of nodes owned by the partition
+func (p *Partition) NumOwned() int {
+	count := 0
+	for _, owned := range p.Owned {
+		if owned {
+			count++
+		}
+	}
+	return count
+}
+
+// Prepare picks the personalization and start values of the owned nodes
+// out of opts and returns their sums, which are needed to normalize them
+// over all partitions
+func (p *Partition) Prepare(opts Options) (personalization, start float64) {
+	p.personalization = p.ownedVector(opts.Personalization)
+	p.start = p.ownedVector(opts.Start)
+	return sumOf(p.personalization), sumOf(p.start)
+}
+
+// Init sets up the starting page rank values and the teleport and
+// dangling distributions from the totals over all partitions
+func (p *Partition) Init(nodes int, personalization, start float64, opts Options) {
+	n := p.Graph.NumNodes()
+	p.teleport = make([]float64, n)
+	p.weights = nil
+	p.norm = opts.Norm
+	for id := 0; id < n; id++ {
+		if !p.Owned[id] {
+			continue
+		}
+		p.teleport[id] = 1 / float64(nodes)
+		if personalization > 0 {
+			p.teleport[id] = p.personalization[id] / personalization
+		}
+		p.Ranks[id] = 1 / float64(nodes)
+		if opts.Start != nil && start > 0 {
+			p.Ranks[id] = p.start[id] / start
+		}
+	}
+	switch opts.Dangling {
+	case DanglingRescale:
+	case DanglingPersonalized:
+		p.weights = p.teleport
+	default:
+		p.weights = make([]float64, n)
+		for id := 0; id < n; id++ {
+			if p.Owned[id] {
+				p.weights[id] = 1 / float64(nodes)
+			}
+		}
+	}
+}
+
+// Scatter returns the rank that flows from the owned nodes into each
+// ghost node, keyed by URL, and the rank held by owned dangling nodes
+func (p *Partition) Scatter() (outgoing map[string]float64, dangling float64) {
+	c := p.Graph
+	outgoing = make(map[string]float64)
+	var danglingSum accumulator
+	for id := 0; id < c.NumNodes(); id++ {
+		if p.Owned[id] {
+			if c.OutDegree[id] == 0 {
+				danglingSum.add(p.Ranks[id])
+			}
+			continue
+		}
+		prestige := 0.0
+		for _, j := range c.In(uint32(id)) {
+			prestige += p.Ranks[j] / float64(c.OutDegree[j])
+		}
+		if prestige != 0 {
+			outgoing[c.URLs[id]] = prestige
+		}
+	}
+	return outgoing, danglingSum.value()
+}
+
+// Receive adds the rank sent by another partition to the owned nodes
+func (p *Partition) Receive(incoming map[string]float64) {
+	for url, value := range incoming {
+		if id, ok := p.Graph.ID(url); ok && p.Owned[id] {
+			p.incoming[id] += value
+		}
+	}
+}
+
+// Update computes the next page rank values of the owned nodes, given
+// the rank held by dangling nodes over all partitions, and returns the
+// sum of the new values
+func (p *Partition) Update(d, dangling float64) float64 {
+	c := p.Graph
+	var total accumulator
+	for id := 0; id < c.NumNodes(); id++ {
+		if !p.Owned[id] {
+			continue
+		}
+		prestige := p.incoming[id]
+		for _, j := range c.In(uint32(id)) {
+			prestige += p.Ranks[j] / float64(c.OutDegree[j])
+		}
+		p.next[id] = (1-d)*p.teleport[id] + d*prestige
+		if p.weights != nil {
+			p.next[id] += d * dangling * p.weights[id]
+		}
+		p.incoming[id] = 0
+		total.add(p.next[id])
+	}
+	return total.value()
+}
+
+// Finish divides the new page rank values by scale, makes them the
+// current values and returns their partial residual to the previous
+// ones. Norm.Combine turns the partial residuals of all partitions into
+// the residual of the iteration.
+func (p *Partition) Finish(scale float64) float64 {
+	var diff accumulator
+	for id, owned := range p.Owned {
+		if owned {
+			p.next[id] /= scale
+			p.norm.add(&diff, math.Abs(p.next[id]-p.Ranks[id]))
+		}
+	}
+	p.Ranks, p.next = p.next, p.Ranks
+	return diff.value()
+}
+
+// OwnedRanks returns the page rank values of the owned nodes keyed by URL
+func (p *Partition) OwnedRanks() map[string]float64 {
+	ranks := make(map[string]float64)
+	for id, url := range p.Graph.URLs {
+		if p.Owned[id] {
+			ranks[url] = p.Ranks[id]
+		}
+	}
+	return ranks
+}
+
+// ComputePartitions runs page rank on partitions that live in the same
+// process, with one goroutine per partition. Like Coordinate, every
+// superstep first exchanges the rank flowing over links between
+// partitions and then updates all partitions, so the result is the same
+// as running Compute on the whole graph, including the *ConvergenceError
+// when opts.MaxIterations runs out first.
+func ComputePartitions(parts []*Partition, owner Owner, opts Options) (*Result, error) {
+	var wg sync.WaitGroup
+	// Runs f for every partition at the same time
+	each := func(f func(idx int, part *Partition)) {
+		for idx, part := range parts {
+			wg.Add(1)
+			go func(idx int, part *Partition) {
+				defer wg.Done()
+				f(idx, part)
+			}(idx, part)
+		}
+		wg.Wait()
+	}
+
+	nodes := 0
+	personalization, start := 0.0, 0.0
+	for _, part := range parts {
+		nodes += part.NumOwned()
+		p, s := part.Prepare(opts)
+		personalization += p
+		start += s
+	}
+	each(func(idx int, part *Partition) {
+		part.Init(nodes, personalization, start, opts)
+	})
+
+	d := opts.Damping
+	outgoing := make([]map[string]float64, len(parts))
+	dangling := make([]float64, len(parts))
+	sums := make([]float64, len(parts))
+	distances := make([]float64, len(parts))
+	result := &Result{}
+	for {
+		each(func(idx int, part *Partition) {
+			outgoing[idx], dangling[idx] = part.Scatter()
+		})
+		// Hand the rank flowing between partitions to the owners
+		batches := make([]map[string]float64, len(parts))
+		for idx := range batches {
+			batches[idx] = make(map[string]float64)
+		}
+		for _, out := range outgoing {
+			for url, value := range out {
+				batches[owner.Of(url)][url] += value
+			}
+		}
+		danglingSum := sumOf(dangling)
+		each(func(idx int, part *Partition) {
+			part.Receive(batches[idx])
+			sums[idx] = part.Update(d, danglingSum)
+		})
+		// Normalize because we want the sum of probabilities to equal one
+		scale := 1.0
+		if opts.Dangling == DanglingRescale {
+			scale = sumOf(sums)
+		}
+		each(func(idx int, part *Partition) {
+			distances[idx] = part.Finish(scale)
+		})
+		residual := opts.Norm.Combine(distances)
+		result.Iterations++
+		result.History = append(result.History, residual)
+		if opts.converged(residual) || opts.reachedMax(result.Iterations) {
+			break
+		}
+	}
+
+	result.Ranks = Ranks{}
+	for _, part := range parts {
+		for url, value := range part.OwnedRanks() {
+			result.Ranks[url] = value
+		}
+	}
+	return result, opts.convergenceErr(result.History)
+}
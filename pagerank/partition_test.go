@@ -0,0 +1,59 @@
+package pagerank
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"../canonical"
+)
+
+func TestComputePartitionsMatchesCompute(t *testing.T) {
+	for _, path := range []string{writeGraph(t, "crawl.gv", testDot), "../dot_files/auth.gv"} {
+		graph, err := ReadGraph(path, "", canonical.Default)
+		if err != nil {
+			t.Fatal(err)
+		}
+		domains, err := GetDomains(path, "", canonical.Default)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// One partition and a few, some of them empty on the small
+		// graph. Init starts every computation afresh, so the partitions
+		// are read once for all dangling modes.
+		for _, workers := range []int{1, 2, 7} {
+			owner := NewOwner(domains, workers)
+			parts := make([]*Partition, workers)
+			for idx := range parts {
+				if parts[idx], err = ReadPartition(path, "", canonical.Default, owner, idx); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, dangling := range []Dangling{DanglingUniform, DanglingPersonalized, DanglingRescale} {
+				name := fmt.Sprintf("%s, dangling mode %d, %d partitions", path, dangling, workers)
+				opts := DefaultOptions()
+				opts.Epsilon = 1e-12
+				opts.Dangling = dangling
+				opts.Personalization = Ranks{graph.Nodes[0]: 1, graph.Nodes[len(graph.Nodes)-1]: 2}
+				want, err := Compute(graph, opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := ComputePartitions(parts, owner, opts)
+				if err != nil {
+					t.Fatalf("%s: %v", name, err)
+				}
+				if len(got.Ranks) != len(want.Ranks) {
+					t.Fatalf("%s: %d ranks, want %d", name, len(got.Ranks), len(want.Ranks))
+				}
+				distance := 0.0
+				for url, rank := range want.Ranks {
+					distance += math.Abs(got.Ranks[url] - rank)
+				}
+				if distance > 1e-9 {
+					t.Errorf("%s: L1 distance to Compute is %g", name, distance)
+				}
+			}
+		}
+	}
+}
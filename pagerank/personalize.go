@@ -0,0 +1,84 @@
+package pagerank
+
+import (
+	"bufio"
+	"os"
+	"strings"
+
+	"../canonical"
+)
+
+// Personalize returns a personalization vector that teleports to each
+// of the seed URLs with equal probability.
+func Personalize(seeds []string) Ranks {
+	personalization := Ranks{}
+	for _, url := range seeds {
+		personalization[url] = 1
+	}
+	return personalization
+}
+
+// DomainSeeds returns the nodes that are part of domain, so a whole
+// domain can be used as seed set. Like IsDomain it takes a domain as
+// Domain groups URLs, such as "admissions.calpoly.edu" for every host
+// ending in admissions.calpoly.edu, or just its first label, such as
+// "admissions".
+func DomainSeeds(nodes []string, domain string) []string {
+	seeds := []string{}
+	for _, url := range nodes {
+		if IsDomain(url, domain) {
+			seeds = append(seeds, url)
+		}
+	}
+	return seeds
+}
+
+// ReadSeeds reads seed URLs from the file at path, one per line. Blank
+// lines and lines starting with # are skipped.
+func ReadSeeds(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	seeds := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		seeds = append(seeds, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return seeds, nil
+}
+
+// CollectSeeds gathers seed URLs from a comma separated list, the seed
+// file at path and every node of domain. Empty arguments are skipped, so
+// with all three empty there are no seeds. The seeds of the list and the
+// file are rewritten by rules like the URLs of the graph, so they name
+// the same nodes.
+func CollectSeeds(list, path, domain string, nodes []string, rules canonical.Rules) ([]string, error) {
+	seeds := []string{}
+	for _, url := range strings.Split(list, ",") {
+		if url = strings.TrimSpace(url); url != "" {
+			seeds = append(seeds, rules.URL(url))
+		}
+	}
+	if path != "" {
+		fileSeeds, err := ReadSeeds(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, url := range fileSeeds {
+			seeds = append(seeds, rules.URL(url))
+		}
+	}
+	if domain != "" {
+		seeds = append(seeds, DomainSeeds(nodes, domain)...)
+	}
+	return seeds, nil
+}
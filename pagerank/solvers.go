@@ -0,0 +1,116 @@
+package pagerank
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Solver selects the iteration ComputeCSR uses to reach the fixed point
+type Solver int
+
+const (
+	// SolverJacobi is the power iteration: every node is ranked from the
+	// values of the previous iteration, so nodes can be ranked in parallel
+	SolverJacobi Solver = iota
+	// SolverGaussSeidel updates the values in place, so nodes later in an
+	// iteration already see the new values of the nodes before them. It
+	// usually needs fewer iterations but runs on one goroutine.
+	SolverGaussSeidel
+	// SolverSOR is successive over-relaxation: Gauss-Seidel that moves
+	// each value Options.Omega times as far as Gauss-Seidel would
+	SolverSOR
+)
+
+var solverNames = []string{"jacobi", "gauss-seidel", "sor"}
+
+func (s Solver) String() string {
+	if s < 0 || int(s) >= len(solverNames) {
+		return fmt.Sprintf("Solver(%d)", int(s))
+	}
+	return solverNames[s]
+}
+
+// ParseSolver returns the solver called name: jacobi, gauss-seidel or sor
+func ParseSolver(name string) (Solver, error) {
+	for s, solverName := range solverNames {
+		if name == solverName {
+			return Solver(s), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown solver %q, want one of %s", name, strings.Join(solverNames, ", "))
+}
+
+// Returns an error if the solver of opts cannot rank with its dangling
+// mode. Rescaling after every iteration makes the page rank equation
+// nonlinear, with fixed points besides the one of the power iteration,
+// some of them with negative ranks, and in-place sweeps may settle on
+// any of them. Only the Jacobi solver supports DanglingRescale.
+func (opts Options) checkSolver() error {
+	if opts.Solver != SolverJacobi && opts.Dangling == DanglingRescale {
+		return fmt.Errorf("the %s solver cannot rank with DanglingRescale, use %s", opts.Solver, SolverJacobi)
+	}
+	return nil
+}
+
+// Runs Gauss-Seidel or SOR sweeps over x, which holds the start vector.
+//
+// Each node is solved for with the newest values of its in-links. A self
+// link, and the share of its own rank a dangling node gets back, sit on
+// the diagonal of the system and move to the left hand side:
+//
+//	x(i) = (rest(i)) / (1 - diag(i))
+//
+// where rest(i) is the equation of ComputeCSR without node i's own
+// terms. The system is linear, so the fixed point is the one of the
+// power iteration. weights is never nil, see checkSolver.
+func gaussSeidel[F real](c *CSR, opts Options, x, teleport, weights []F) (*CSRResult, error) {
+	n := c.NumNodes()
+	d := opts.Damping
+	omega := 1.0
+	if opts.Solver == SolverSOR && opts.Omega != 0 {
+		omega = opts.Omega
+	}
+	previous := make([]F, n)
+	danglingSum := danglingRank(c, x, 0, n)
+	result := &CSRResult{}
+	for {
+		copy(previous, x)
+		for i := 0; i < n; i++ {
+			prestige, self := 0.0, 0.0
+			w := c.InWeights(uint32(i))
+			for k, j := range c.In(uint32(i)) {
+				if int(j) == i {
+					self += c.flow(1, j, w, k)
+					continue
+				}
+				prestige += c.flow(float64(x[j]), j, w, k)
+			}
+			rest := (1-d)*float64(teleport[i]) + d*prestige
+			diag := d * self
+			dangling := c.OutDegree[i] == 0
+			old := float64(x[i])
+			others := danglingSum
+			if dangling {
+				others -= old
+				diag += d * float64(weights[i])
+			}
+			rest += d * others * float64(weights[i])
+			x[i] = F((1-omega)*old + omega*rest/(1-diag))
+			if dangling {
+				danglingSum += float64(x[i]) - old
+			}
+		}
+		// In-place updates do not keep the sum at one like the power
+		// iteration does. The fixed point sums to one, so normalizing
+		// only removes error.
+		normalize(x)
+		danglingSum = danglingRank(c, x, 0, n)
+		result.Iterations++
+		residual := opts.Norm.finish(partialResidual(opts.Norm, previous, x))
+		result.History = append(result.History, residual)
+		if opts.converged(residual) || opts.reachedMax(result.Iterations) {
+			result.Ranks = convert[float64](x)
+			return result, opts.convergenceErr(result.History)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package pagerank
+
+import (
+	"errors"
+	"testing"
+
+	"../canonical"
+)
+
+func TestSolversMatchJacobi(t *testing.T) {
+	auth, err := ReadCSR("../dot_files/auth.gv", "", canonical.Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	graphs := map[string]*CSR{"auth.gv": auth, "random": randomCSR(3000, 2)}
+	modes := map[string]Dangling{
+		"uniform":      DanglingUniform,
+		"personalized": DanglingPersonalized,
+		"rescale":      DanglingRescale,
+	}
+	for graphName, graph := range graphs {
+		for modeName, mode := range modes {
+			opts := DefaultOptions()
+			opts.Epsilon = 1e-12
+			opts.Dangling = mode
+			opts.Personalization = Ranks{graph.URLs[0]: 1, graph.URLs[len(graph.URLs)/2]: 3}
+			want, err := ComputeCSR(graph, opts)
+			if err != nil {
+				t.Fatalf("%s, %s: %v", graphName, modeName, err)
+			}
+			for _, solver := range []Solver{SolverGaussSeidel, SolverSOR} {
+				name := graphName + ", " + modeName + ", " + solver.String()
+				opts.Solver = solver
+				opts.Omega = 1.1
+				got, err := ComputeCSR(graph, opts)
+				if mode == DanglingRescale {
+					var convergence *ConvergenceError
+					if err == nil || errors.As(err, &convergence) || got != nil {
+						t.Errorf("%s: got ranks and error %v, want the combination rejected", name, err)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("%s: %v", name, err)
+				}
+				// Over-relaxation overshoots ranks that are zero, but
+				// only by the tolerance
+				for id, rank := range got.Ranks {
+					if rank < -opts.Epsilon {
+						t.Fatalf("%s: node %d has the negative rank %g", name, id, rank)
+					}
+				}
+				if gap := NormL1.Residual(got.Ranks, want.Ranks); gap > 1e-9 {
+					t.Errorf("%s: L1 distance to the power iteration is %g", name, gap)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package pagerank
+
+import "math"
+
+// Floating point types the engines can keep their rank vectors in
+type real interface {
+	~float32 | ~float64
+}
+
+// accumulator adds up float64 values with Neumaier's variant of Kahan
+// summation. The rounding error of every addition is carried in a
+// separate compensation term, so the error of a sum over millions of
+// nodes stays a few units in the last place instead of growing with
+// the number of nodes.
+type accumulator struct {
+	sum, compensation float64
+}
+
+func (a *accumulator) add(x float64) {
+	t := a.sum + x
+	if math.Abs(a.sum) >= math.Abs(x) {
+		a.compensation += (a.sum - t) + x
+	} else {
+		a.compensation += (x - t) + a.sum
+	}
+	a.sum = t
+}
+
+func (a *accumulator) value() float64 {
+	return a.sum + a.compensation
+}
+
+// Returns the compensated sum of v
+func sumOf[F real](v []F) float64 {
+	var acc accumulator
+	for _, value := range v {
+		acc.add(float64(value))
+	}
+	return acc.value()
+}
+
+// Returns v as a slice of T, or v itself when it already is one. A nil
+// slice stays nil.
+func convert[T, F real](v []F) []T {
+	if same, ok := any(v).([]T); ok {
+		return same
+	}
+	if v == nil {
+		return nil
+	}
+	out := make([]T, len(v))
+	for i, value := range v {
+		out[i] = T(value)
+	}
+	return out
+}
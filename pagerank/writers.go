@@ -0,0 +1,238 @@
+package pagerank
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// GraphWriter writes a link graph in one output format
+type GraphWriter interface {
+	Write(w io.Writer, g *Graph) error
+}
+
+// GraphWriterFunc adapts a function to the GraphWriter interface
+type GraphWriterFunc func(w io.Writer, g *Graph) error
+
+// Write calls f(w, g)
+func (f GraphWriterFunc) Write(w io.Writer, g *Graph) error {
+	return f(w, g)
+}
+
+var writers = map[string]GraphWriter{
+	FormatDot:          GraphWriterFunc(writeDot),
+	FormatEdgeList:     GraphWriterFunc(writeEdgeList),
+	FormatSNAP:         GraphWriterFunc(writeSNAP),
+	FormatAdjacency:    GraphWriterFunc(writeAdjacencyList),
+	FormatMatrixMarket: GraphWriterFunc(writeMatrixMarket),
+}
+
+// RegisterWriter makes w available under the format name
+func RegisterWriter(format string, w GraphWriter) {
+	writers[format] = w
+}
+
+// WriteFile writes g to path in the given output format. An empty
+// format is detected from the extension, and paths ending in .gz are
+// gzip compressed.
+func WriteFile(path, format string, g *Graph) error {
+	if format == "" {
+		var err error
+		if format, err = DetectFormat(path); err != nil {
+			return err
+		}
+	}
+	writer, ok := writers[format]
+	if !ok {
+		return fmt.Errorf("unknown output format %q", format)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var w io.Writer = file
+	var gz *gzip.Writer
+	if strings.HasSuffix(strings.ToLower(path), ".gz") {
+		gz = gzip.NewWriter(file)
+		w = gz
+	}
+	buf := bufio.NewWriter(w)
+	if err := writer.Write(buf, g); err != nil {
+		return err
+	}
+	if err := buf.Flush(); err != nil {
+		return err
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return err
+		}
+	}
+	return file.Close()
+}
+
+// Returns the outlinks of every node in the order of g.Nodes
+func outLinks(g *Graph) map[string][]string {
+	out := make(map[string][]string)
+	for _, dest := range g.Nodes {
+		for _, src := range g.AdjacencyList[dest] {
+			out[src] = append(out[src], dest)
+		}
+	}
+	return out
+}
+
+// Quotes id as a dot ID
+func quoteDotID(id string) string {
+	return "\"" + strings.Replace(id, "\"", "\\\"", -1) + "\""
+}
+
+// Formats attrs as a dot attribute list, empty when there are none
+func dotAttrs(attrs Attrs) string {
+	if len(attrs) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	list := make([]string, len(keys))
+	for i, k := range keys {
+		list[i] = quoteDotID(k) + "=" + quoteDotID(attrs[k])
+	}
+	return " [" + strings.Join(list, ", ") + "]"
+}
+
+// Writes a dot file. Nodes are declared on their own when they have
+// attributes or no links at all.
+func writeDot(w io.Writer, g *Graph) error {
+	fmt.Fprintln(w, "digraph {")
+	out := outLinks(g)
+	for _, url := range g.Nodes {
+		if len(g.NodeAttrs[url]) > 0 || (len(out[url]) == 0 && len(g.AdjacencyList[url]) == 0) {
+			fmt.Fprintf(w, "%s%s;\n", quoteDotID(url), dotAttrs(g.NodeAttrs[url]))
+		}
+	}
+	for _, src := range g.Nodes {
+		for _, dest := range out[src] {
+			fmt.Fprintf(w, "%s -> %s%s;\n", quoteDotID(src), quoteDotID(dest), dotAttrs(g.EdgeAttrs[Edge{src, dest}]))
+		}
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+// Edge lists and adjacency lists cannot hold IDs with white space
+func checkPlain(id string) error {
+	if id == "" || strings.IndexFunc(id, unicode.IsSpace) >= 0 {
+		return fmt.Errorf("node %q cannot be written without quoting", id)
+	}
+	return nil
+}
+
+// Writes one "src<TAB>dest[<TAB>weight]" line per link
+func writeEdges(w io.Writer, g *Graph) error {
+	out := outLinks(g)
+	for _, src := range g.Nodes {
+		for _, dest := range out[src] {
+			if err := checkPlain(src); err != nil {
+				return err
+			}
+			if err := checkPlain(dest); err != nil {
+				return err
+			}
+			if weight, ok := g.EdgeAttrs[Edge{src, dest}]["weight"]; ok {
+				fmt.Fprintf(w, "%s\t%s\t%s\n", src, dest, weight)
+			} else {
+				fmt.Fprintf(w, "%s\t%s\n", src, dest)
+			}
+		}
+	}
+	return nil
+}
+
+func writeEdgeList(w io.Writer, g *Graph) error {
+	return writeEdges(w, g)
+}
+
+// Writes an edge list with the header SNAP files start with
+func writeSNAP(w io.Writer, g *Graph) error {
+	edges := 0
+	for _, inLinks := range g.AdjacencyList {
+		edges += len(inLinks)
+	}
+	fmt.Fprintln(w, "# Directed graph")
+	fmt.Fprintf(w, "# Nodes: %d Edges: %d\n", len(g.Nodes), edges)
+	fmt.Fprintln(w, "# FromNodeId\tToNodeId")
+	return writeEdges(w, g)
+}
+
+// Writes one "src dest1 dest2 ..." line per node
+func writeAdjacencyList(w io.Writer, g *Graph) error {
+	out := outLinks(g)
+	for _, src := range g.Nodes {
+		if err := checkPlain(src); err != nil {
+			return err
+		}
+		line := []string{src}
+		for _, dest := range out[src] {
+			if err := checkPlain(dest); err != nil {
+				return err
+			}
+			line = append(line, dest)
+		}
+		fmt.Fprintln(w, strings.Join(line, " "))
+	}
+	return nil
+}
+
+// Writes a Matrix Market coordinate file. Node i of g.Nodes becomes
+// index i+1, and its name is kept in a "% i name" comment that the
+// Matrix Market reader turns back into the name of the node.
+func writeMatrixMarket(w io.Writer, g *Graph) error {
+	index := make(map[string]int, len(g.Nodes))
+	for i, url := range g.Nodes {
+		index[url] = i + 1
+	}
+	out := outLinks(g)
+	edges := 0
+	weighted := false
+	for _, dests := range out {
+		edges += len(dests)
+	}
+	for _, attrs := range g.EdgeAttrs {
+		if _, ok := attrs["weight"]; ok {
+			weighted = true
+		}
+	}
+	if weighted {
+		fmt.Fprintln(w, "%%MatrixMarket matrix coordinate real general")
+	} else {
+		fmt.Fprintln(w, "%%MatrixMarket matrix coordinate pattern general")
+	}
+	for i, url := range g.Nodes {
+		fmt.Fprintf(w, "%% %d %s\n", i+1, url)
+	}
+	fmt.Fprintf(w, "%d %d %d\n", len(g.Nodes), len(g.Nodes), edges)
+	for _, src := range g.Nodes {
+		for _, dest := range out[src] {
+			if !weighted {
+				fmt.Fprintf(w, "%d %d\n", index[src], index[dest])
+				continue
+			}
+			weight, ok := g.EdgeAttrs[Edge{src, dest}]["weight"]
+			if !ok {
+				weight = "1"
+			}
+			fmt.Fprintf(w, "%d %d %s\n", index[src], index[dest], weight)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+// Package registrable finds the registrable domain of a host in the
+// public suffix list, which the crawler scopes crawls by and the
+// pagerank package groups URLs by.
+package registrable
+
+import (
+	"net"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Domain returns the part of host that a registrant owns: the public
+// suffix, such as "edu" or "co.uk", plus the label before it. So
+// "ceng.calpoly.edu" gives "calpoly.edu" and "www.bbc.co.uk" gives
+// "bbc.co.uk". A port is dropped, and IP addresses and hosts that are a
+// public suffix themselves are returned as they are.
+func Domain(host string) string {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return domain
+}
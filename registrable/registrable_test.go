@@ -0,0 +1,37 @@
+package registrable
+
+import "testing"
+
+func TestDomain(t *testing.T) {
+	for host, want := range map[string]string{
+		"calpoly.edu":           "calpoly.edu",
+		"ceng.calpoly.edu":      "calpoly.edu",
+		"www.ceng.calpoly.edu":  "calpoly.edu",
+		"WWW.CalPoly.EDU.":      "calpoly.edu",
+		"www.bbc.co.uk":         "bbc.co.uk",
+		"bbc.co.uk":             "bbc.co.uk",
+		"a.b.example.github.io": "example.github.io",
+		"localhost":             "localhost",
+
+		// Ports are dropped
+		"ceng.calpoly.edu:8080": "calpoly.edu",
+		"www.bbc.co.uk:443":     "bbc.co.uk",
+
+		// IP addresses are returned as they are
+		"129.65.1.2":         "129.65.1.2",
+		"129.65.1.2:8080":    "129.65.1.2",
+		"::1":                "::1",
+		"[2001:db8::1]":      "2001:db8::1",
+		"[2001:DB8::1]:8443": "2001:db8::1",
+
+		// So are hosts that are a public suffix themselves
+		"edu":       "edu",
+		"co.uk":     "co.uk",
+		"github.io": "github.io",
+		"co.uk:80":  "co.uk",
+	} {
+		if got := Domain(host); got != want {
+			t.Errorf("Domain(%q) = %q, want %q", host, got, want)
+		}
+	}
+}